@@ -0,0 +1,99 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// openFS picks the filesystem for the path given on the command line:
+// zip and tar archives are opened as is, anything else is a directory.
+// Tar file bodies are only kept when contents is set.
+// The returned func releases the archive once the tree is printed.
+func openFS(path string, contents bool) (fs.FS, func() error, error) {
+	noop := func() error { return nil }
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return os.DirFS(path), noop, nil
+	}
+
+	lower := strings.ToLower(path)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		reader, err := zip.OpenReader(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		return reader, reader.Close, nil
+	case strings.HasSuffix(lower, ".tar"):
+		fsys, err := readTarFile(path, false, contents)
+		return fsys, noop, err
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		fsys, err := readTarFile(path, true, contents)
+		return fsys, noop, err
+	default:
+		return os.DirFS(path), noop, nil
+	}
+}
+
+func readTarFile(path string, compressed, contents bool) (fs.FS, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var input io.Reader = file
+	if compressed {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		input = gz
+	}
+	return readTar(input, contents)
+}
+
+// readTar loads the tar headers into memory, tar has no index so there
+// is no way to list a directory without reading it all. File bodies are
+// skipped unless contents is set, a listing only needs the sizes.
+func readTar(input io.Reader, contents bool) (fs.FS, error) {
+	fsys := newMemFS()
+	reader := tar.NewReader(input)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return fsys, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		info := header.FileInfo()
+		switch header.Typeflag {
+		case tar.TypeDir:
+			fsys.add(header.Name, info.Mode(), 0, info.ModTime(), nil, "")
+		case tar.TypeSymlink:
+			fsys.add(header.Name, info.Mode(), 0, info.ModTime(), nil, header.Linkname)
+		case tar.TypeLink:
+			// a hard link is the same file as its target, it is shown as a link
+			// so that it is neither counted twice nor reported as a duplicate
+			fsys.add(header.Name, fs.ModeSymlink|info.Mode().Perm(), 0, info.ModTime(), nil, header.Linkname)
+		default:
+			// regular files and the rest: devices, fifos, ... keep their mode
+			var data []byte
+			if contents && info.Mode().IsRegular() {
+				data, err = io.ReadAll(reader)
+				if err != nil {
+					return nil, err
+				}
+			}
+			fsys.add(header.Name, info.Mode(), header.Size, info.ModTime(), data, "")
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+const (
+	charsetUTF8  = "utf8"
+	charsetASCII = "ascii"
+)
+
+// charset is the set of strings the text output draws the tree with
+type charset struct {
+	middle   string
+	last     string
+	pipe     string
+	space    string
+	ellipsis string
+}
+
+var charsets = map[string]charset{
+	charsetUTF8:  {middle: "├───", last: "└───", pipe: "│\t", space: "\t", ellipsis: "…"},
+	charsetASCII: {middle: "|-- ", last: "`-- ", pipe: "|\t", space: "\t", ellipsis: "..."},
+}
+
+// chars returns the selected charset, falling back to utf8
+func (opts options) chars() charset {
+	if chars, ok := charsets[opts.charset]; ok {
+		return chars
+	}
+	return charsets[charsetUTF8]
+}
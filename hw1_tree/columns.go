@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const mtimeLayout = "2006-01-02 15:04"
+
+// columns renders the metadata requested on the command line,
+// padded so names start at the same offset after the prefix
+func columns(item *node, opts options) string {
+	var fields []string
+	if opts.showPerms {
+		fields = append(fields, item.mode.String())
+	}
+	if opts.showOwner {
+		fields = append(fields, fmt.Sprintf("%-*s", opts.ownerWidth, item.owner))
+	}
+	if opts.showMtime {
+		mtime := strings.Repeat(" ", len(mtimeLayout))
+		if !item.modTime.IsZero() {
+			mtime = item.modTime.Format(mtimeLayout)
+		}
+		fields = append(fields, mtime)
+	}
+	if len(fields) == 0 {
+		return ""
+	}
+	return "[" + strings.Join(fields, " ") + "]  "
+}
+
+func maxOwnerWidth(dir *node) int {
+	width := 0
+	for _, item := range dir.Children {
+		if len(item.owner) > width {
+			width = len(item.owner)
+		}
+		if w := maxOwnerWidth(item); w > width {
+			width = w
+		}
+	}
+	return width
+}
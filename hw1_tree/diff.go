@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	diffAdded   = "added"
+	diffRemoved = "removed"
+	diffChanged = "changed"
+)
+
+var diffMarks = map[string]string{
+	diffAdded:   "[+]",
+	diffRemoved: "[-]",
+	diffChanged: "[~]",
+}
+
+// writeDiff prints the merged tree of two directories, archives or
+// JSON snapshots saved with -format=json
+func writeDiff(output io.Writer, oldPath, newPath string, opts options) error {
+	oldRoot, oldErr := loadTree(oldPath, opts)
+	if oldRoot == nil {
+		return oldErr
+	}
+	newRoot, newErr := loadTree(newPath, opts)
+	if newRoot == nil {
+		return newErr
+	}
+
+	root := diffNodes(oldRoot, newRoot)
+	if err := renderTree(output, root, opts); err != nil {
+		return err
+	}
+	if oldErr != nil {
+		return oldErr
+	}
+	return newErr
+}
+
+// loadTree reads a snapshot or walks the path, like readTree
+// it may return both the tree and a *treeError
+func loadTree(path string, opts options) (*node, error) {
+	if strings.HasSuffix(strings.ToLower(path), ".json") {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		root := &node{}
+		if err := json.Unmarshal(data, root); err != nil {
+			return nil, err
+		}
+		return root, nil
+	}
+
+	fsys, closeFS, err := openFS(path, false)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFS()
+	return readTree(fsys, filepath.Base(path), opts)
+}
+
+// diffNodes merges the children of two versions of a directory,
+// entries are matched by name and type and kept in name order
+func diffNodes(oldDir, newDir *node) *node {
+	merged := *newDir
+	merged.Children = nil
+
+	oldItems, newItems := byName(oldDir.Children), byName(newDir.Children)
+	for len(oldItems) > 0 || len(newItems) > 0 {
+		switch {
+		case len(newItems) == 0 || len(oldItems) > 0 && oldItems[0].Name < newItems[0].Name:
+			merged.Children = append(merged.Children, markTree(oldItems[0], diffRemoved))
+			oldItems = oldItems[1:]
+		case len(oldItems) == 0 || newItems[0].Name < oldItems[0].Name:
+			merged.Children = append(merged.Children, markTree(newItems[0], diffAdded))
+			newItems = newItems[1:]
+		default:
+			merged.Children = append(merged.Children, diffEntry(oldItems[0], newItems[0])...)
+			oldItems, newItems = oldItems[1:], newItems[1:]
+		}
+	}
+	return &merged
+}
+
+// diffEntry compares two entries with the same name, an entry that
+// changed its type is shown as removed and added again
+func diffEntry(oldItem, newItem *node) []*node {
+	switch {
+	case oldItem.Type != newItem.Type:
+		return []*node{markTree(oldItem, diffRemoved), markTree(newItem, diffAdded)}
+	case newItem.isDir():
+		return []*node{diffNodes(oldItem, newItem)}
+	case oldItem.Size != newItem.Size || oldItem.Target != newItem.Target:
+		changed := *newItem
+		changed.Diff = diffChanged
+		changed.OldSize = oldItem.Size
+		return []*node{&changed}
+	default:
+		return []*node{newItem}
+	}
+}
+
+// markTree copies the subtree with every entry marked as added or removed
+func markTree(item *node, mark string) *node {
+	marked := *item
+	marked.Diff = mark
+	marked.Children = make([]*node, 0, len(item.Children))
+	for _, child := range item.Children {
+		marked.Children = append(marked.Children, markTree(child, mark))
+	}
+	return &marked
+}
+
+// byName returns a copy of nodes in name order, snapshots may
+// have been saved with another sort mode
+func byName(nodes []*node) []*node {
+	sorted := append([]*node(nil), nodes...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	return sorted
+}
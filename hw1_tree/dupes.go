@@ -0,0 +1,123 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// dupGroup is a set of files with the same content
+type dupGroup struct {
+	id    int
+	size  int64
+	files []*node
+}
+
+// findDuplicates marks files with equal content with a common group id.
+// Only files of the same size are hashed, so unique sizes cost nothing.
+func findDuplicates(fsys fs.FS, root *node) []*dupGroup {
+	var files []*node
+	collectFiles(root, &files)
+
+	// with --follow the same file can be reached through a linked directory,
+	// only its first path takes part so it is not its own duplicate
+	bySize := map[int64][]*node{}
+	var unique []*node
+	for _, file := range files {
+		if file.Size > 0 && !sameFileSeen(bySize[file.Size], file) {
+			bySize[file.Size] = append(bySize[file.Size], file)
+			unique = append(unique, file)
+		}
+	}
+
+	byHash := map[string]*dupGroup{}
+	var groups []*dupGroup
+	for _, file := range unique {
+		if len(bySize[file.Size]) < 2 {
+			continue
+		}
+		hash, err := hashFile(fsys, file.path)
+		if err != nil {
+			continue
+		}
+		group, ok := byHash[hash]
+		if !ok {
+			group = &dupGroup{size: file.Size}
+			byHash[hash] = group
+			groups = append(groups, group)
+		}
+		group.files = append(group.files, file)
+	}
+
+	// ids go in the order groups appear in the tree, singles are dropped
+	var dupes []*dupGroup
+	for _, group := range groups {
+		if len(group.files) < 2 {
+			continue
+		}
+		group.id = len(dupes) + 1
+		for _, file := range group.files {
+			file.Dup = group.id
+		}
+		dupes = append(dupes, group)
+	}
+	return dupes
+}
+
+// collectFiles lists regular files in the order they are printed
+func collectFiles(dir *node, files *[]*node) {
+	for _, item := range dir.Children {
+		if item.isDir() {
+			collectFiles(item, files)
+		} else if item.Type == typeFile {
+			*files = append(*files, item)
+		}
+	}
+}
+
+// sameFileSeen reports whether file is one of seen under another path
+func sameFileSeen(seen []*node, file *node) bool {
+	for _, other := range seen {
+		if os.SameFile(other.info, file.info) {
+			return true
+		}
+	}
+	return false
+}
+
+func hashFile(fsys fs.FS, name string) (string, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return string(hash.Sum(nil)), nil
+}
+
+func printDuplicates(output io.Writer, groups []*dupGroup, opts options) {
+	var reclaimable int64
+	fmt.Fprintln(output)
+	for _, group := range groups {
+		paths := make([]string, 0, len(group.files))
+		for _, file := range group.files {
+			paths = append(paths, file.path)
+		}
+		fmt.Fprintf(output, "[dup %d] %s of %s: %s\n", group.id, plural(len(group.files), "file", "files"),
+			formatSize(group.size, opts.human), strings.Join(paths, ", "))
+		reclaimable += group.size * int64(len(group.files)-1)
+	}
+	// formatSize says "empty" for zero, which reads wrong in a sentence
+	size := "0b"
+	if reclaimable > 0 {
+		size = formatSize(reclaimable, opts.human)
+	}
+	fmt.Fprintf(output, "%s, reclaimable %s\n",
+		plural(len(groups), "duplicate group", "duplicate groups"), size)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+const (
+	errorsInline  = "inline"
+	errorsCollect = "collect"
+	errorsFail    = "fail"
+)
+
+// treeError is returned when the tree was printed but some of the
+// nested directories could not be read
+type treeError struct {
+	errs []error
+}
+
+func (te *treeError) Error() string {
+	lines := make([]string, 0, len(te.errs))
+	for _, err := range te.errs {
+		lines = append(lines, err.Error())
+	}
+	return fmt.Sprintf("tree is incomplete, %s:\n%s",
+		plural(len(te.errs), "error", "errors"), strings.Join(lines, "\n"))
+}
+
+// errorReason strips the operation and path from err, the tree
+// already shows where it happened
+func errorReason(err error) string {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return pathErr.Err.Error()
+	}
+	return err.Error()
+}
+
+// dirError applies the error policy to a directory that failed to read,
+// only the fail-fast policy stops the walk
+func (w *walker) dirError(dir *node, err error) error {
+	dir.failed = true
+	switch w.opts.errors {
+	case errorsFail:
+		return err
+	case errorsCollect:
+		w.errs = append(w.errs, err)
+	default:
+		dir.Err = errorReason(err)
+	}
+	return nil
+}
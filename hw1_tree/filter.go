@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// patterns is a repeatable command line flag with glob patterns
+type patterns []string
+
+func (p *patterns) String() string {
+	return strings.Join(*p, ",")
+}
+
+func (p *patterns) Set(value string) error {
+	if _, err := filepath.Match(value, ""); err != nil {
+		return err
+	}
+	*p = append(*p, value)
+	return nil
+}
+
+func (p patterns) match(name string) bool {
+	for _, pattern := range p {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// visible reports whether a directory entry passes the filters.
+// Include patterns, size and time limits apply only to files, so
+// directories are always descended into unless they are excluded.
+func visible(item fs.FileInfo, opts options) bool {
+	if opts.exclude.match(item.Name()) {
+		return false
+	}
+	if item.IsDir() {
+		return true
+	}
+	if len(opts.include) > 0 && !opts.include.match(item.Name()) {
+		return false
+	}
+	if opts.minSize > 0 && item.Size() < int64(opts.minSize) {
+		return false
+	}
+	if opts.maxSize > 0 && item.Size() > int64(opts.maxSize) {
+		return false
+	}
+	if !opts.newerThan.IsZero() && !item.ModTime().After(opts.newerThan.Time) {
+		return false
+	}
+	if !opts.olderThan.IsZero() && !item.ModTime().Before(opts.olderThan.Time) {
+		return false
+	}
+	return true
+}
+
+// sizeFlag is a byte count given as 512, 10K, 1.5M and so on, 0 means no limit
+type sizeFlag int64
+
+var sizeMultipliers = map[string]float64{"": 1, "B": 1, "K": 1 << 10, "M": 1 << 20, "G": 1 << 30, "T": 1 << 40}
+
+func (s *sizeFlag) String() string {
+	return strconv.FormatInt(int64(*s), 10)
+}
+
+func (s *sizeFlag) Set(value string) error {
+	upper := strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(value)), "B")
+	number := strings.TrimRight(upper, "KMGT")
+	multiplier, ok := sizeMultipliers[upper[len(number):]]
+	size, err := strconv.ParseFloat(number, 64)
+	if !ok || err != nil || size < 0 {
+		return fmt.Errorf("invalid size %q", value)
+	}
+	*s = sizeFlag(size * multiplier)
+	return nil
+}
+
+// timeFlag is a point in time given either as a date or as an age
+// relative to now, like 36h or 7d
+type timeFlag struct {
+	time.Time
+}
+
+var timeLayouts = []string{time.RFC3339, "2006-01-02 15:04", "2006-01-02"}
+
+func (t *timeFlag) String() string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+func (t *timeFlag) Set(value string) error {
+	for _, layout := range timeLayouts {
+		if parsed, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			t.Time = parsed
+			return nil
+		}
+	}
+	age, err := parseAge(value)
+	if err != nil {
+		return err
+	}
+	t.Time = time.Now().Add(-age)
+	return nil
+}
+
+// parseAge is time.ParseDuration that also knows days and weeks
+func parseAge(value string) (time.Duration, error) {
+	units := map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour}
+	for suffix, unit := range units {
+		if number := strings.TrimSuffix(value, suffix); number != value {
+			count, err := strconv.ParseFloat(number, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid age %q", value)
+			}
+			return time.Duration(count * float64(unit)), nil
+		}
+	}
+	age, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid age %q", value)
+	}
+	return age, nil
+}
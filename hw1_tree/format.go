@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+const (
+	formatText = "text"
+	formatJSON = "json"
+	formatXML  = "xml"
+
+	formatHTML     = "html"
+	formatMarkdown = "markdown"
+)
+
+func writeJSON(output io.Writer, root *node) error {
+	enc := json.NewEncoder(output)
+	enc.SetIndent("", "  ")
+	return enc.Encode(root)
+}
+
+// xmlNode gives the root element the same name as the nested ones
+type xmlNode struct {
+	XMLName xml.Name `xml:"node"`
+	*node
+}
+
+func writeXML(output io.Writer, root *node) error {
+	if _, err := io.WriteString(output, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(output)
+	enc.Indent("", "  ")
+	if err := enc.Encode(xmlNode{node: root}); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(output)
+	return err
+}
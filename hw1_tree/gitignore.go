@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// ignoreRule is a single pattern line of a .gitignore file
+type ignoreRule struct {
+	segments []string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// ignoreFile holds the rules of one .gitignore and the directory it lives in
+type ignoreFile struct {
+	dir   string
+	rules []ignoreRule
+}
+
+// ignoreStack is the list of .gitignore files from the root down to the
+// directory being read, deeper files take precedence
+type ignoreStack []*ignoreFile
+
+func parseIgnoreRule(line string) (ignoreRule, bool) {
+	rule := ignoreRule{}
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return rule, false
+	}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		rule.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	if line == "" {
+		return rule, false
+	}
+	rule.segments = strings.Split(line, "/")
+	return rule, true
+}
+
+func readIgnoreFile(fsys fs.FS, dir string) (*ignoreFile, error) {
+	file, err := fsys.Open(path.Join(dir, ".gitignore"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	ignore := &ignoreFile{dir: dir}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if rule, ok := parseIgnoreRule(scanner.Text()); ok {
+			ignore.rules = append(ignore.rules, rule)
+		}
+	}
+	return ignore, scanner.Err()
+}
+
+// push returns the stack extended with the .gitignore of dir, if there is one
+func (s ignoreStack) push(fsys fs.FS, dir string) ignoreStack {
+	ignore, err := readIgnoreFile(fsys, dir)
+	if err != nil || ignore == nil {
+		return s
+	}
+	return append(s[:len(s):len(s)], ignore)
+}
+
+// ignored reports whether the entry at fullPath is excluded, the last
+// matching rule wins just like in git
+func (s ignoreStack) ignored(fullPath string, isDir bool) bool {
+	ignored := false
+	for _, ignore := range s {
+		rel := fullPath
+		if ignore.dir != "." {
+			rel = strings.TrimPrefix(fullPath, ignore.dir+"/")
+		}
+		segments := strings.Split(rel, "/")
+		for _, rule := range ignore.rules {
+			if rule.match(segments, isDir) {
+				ignored = !rule.negate
+			}
+		}
+	}
+	return ignored
+}
+
+func (r ignoreRule) match(segments []string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if !r.anchored {
+		return matchSegments(r.segments, segments[len(segments)-1:])
+	}
+	return matchSegments(r.segments, segments)
+}
+
+// matchSegments matches path segments against pattern segments,
+// where "**" stands for any number of segments
+func matchSegments(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchSegments(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], segments[1:])
+}
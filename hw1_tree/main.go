@@ -1,67 +1,287 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"io"
-	"io/ioutil"
+	"io/fs"
 	"os"
+	"path/filepath"
 	"strconv"
 )
 
 func main() {
 	out := os.Stdout
-	if !(len(os.Args) == 2 || len(os.Args) == 3) {
-		panic("usage go run main.go . [-f]")
+	var err error
+	if len(os.Args) > 1 && os.Args[1] == "diff" {
+		paths, opts, parseErr := parseArgs(os.Args[2:], 2)
+		if parseErr != nil {
+			panic(parseErr.Error())
+		}
+		err = writeDiff(out, paths[0], paths[1], opts)
+	} else {
+		paths, opts, parseErr := parseArgs(os.Args[1:], 1)
+		if parseErr != nil {
+			panic(parseErr.Error())
+		}
+		err = writeTree(out, paths[0], opts)
+	}
+	var incomplete *treeError
+	if errors.As(err, &incomplete) {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
-	path := os.Args[1]
-	printFiles := len(os.Args) == 3 && os.Args[2] == "-f"
-	err := dirTree(out, path, printFiles)
 	if err != nil {
 		panic(err.Error())
 	}
 }
 
+// parseArgs accepts flags both before and after the paths,
+// so the original "go run main.go . -f" form keeps working
+func parseArgs(args []string, pathCount int) ([]string, options, error) {
+	opts := options{}
+	flags := flag.NewFlagSet("tree", flag.ContinueOnError)
+	flags.BoolVar(&opts.printFiles, "f", false, "print files")
+	flags.StringVar(&opts.format, "format", formatText, "output format: text, json, xml, html or markdown")
+	flags.IntVar(&opts.depth, "L", 0, "max display depth of the tree, 0 means no limit")
+	flags.Var(&opts.include, "include", "list only files matching the glob pattern, can be repeated")
+	flags.Var(&opts.exclude, "exclude", "skip entries matching the glob pattern, can be repeated")
+	flags.Var(&opts.minSize, "min-size", "list only files of at least this size, e.g. 100M")
+	flags.Var(&opts.maxSize, "max-size", "list only files of at most this size, e.g. 10K")
+	flags.Var(&opts.newerThan, "newer-than", "list only files modified after a date or within an age, e.g. 7d")
+	flags.Var(&opts.olderThan, "older-than", "list only files modified before a date or longer ago than an age")
+	flags.BoolVar(&opts.prune, "prune", false, "hide directories that are empty after filtering")
+	flags.BoolVar(&opts.gitignore, "gitignore", false, "hide entries ignored by .gitignore files")
+	flags.BoolVar(&opts.dirSizes, "du", false, "print cumulative size and file count of directories")
+	flags.BoolVar(&opts.human, "h", false, "print sizes in human readable units (K, M, G)")
+	flags.BoolVar(&opts.summary, "summary", false, "print directory and file totals after the tree")
+	flags.BoolVar(&opts.follow, "follow", false, "descend into symlinked directories")
+	flags.StringVar(&opts.errors, "errors", errorsInline, "unreadable directories: inline, collect or fail")
+	flags.IntVar(&opts.workers, "workers", 1, "number of directories read in parallel")
+	flags.StringVar(&opts.sortBy, "sort", sortName, "sort entries by name, size, mtime or type")
+	flags.BoolVar(&opts.dirsFirst, "dirsfirst", false, "list directories before files")
+	flags.BoolVar(&opts.reverse, "r", false, "reverse the sort order")
+	flags.BoolVar(&opts.showPerms, "p", false, "print permissions")
+	flags.BoolVar(&opts.showOwner, "u", false, "print the owner")
+	flags.BoolVar(&opts.showMtime, "D", false, "print the modification time")
+	flags.BoolVar(&opts.dupes, "dupes", false, "mark files with identical content, needs -f")
+	flags.IntVar(&opts.maxEntries, "max-entries", 0, "print at most this many entries per directory in text output")
+	flags.StringVar(&opts.charset, "charset", charsetUTF8, "prefix drawing characters: utf8 or ascii")
+
+	var paths []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, opts, err
+		}
+		if flags.NArg() == 0 {
+			break
+		}
+		paths = append(paths, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+	if len(paths) != pathCount {
+		return nil, opts, errors.New("usage go run main.go [diff old] . [-f] [flags], use - to read paths from stdin")
+	}
+	if opts.depth < 0 {
+		return nil, opts, errors.New("depth must not be negative")
+	}
+	if opts.maxEntries < 0 {
+		return nil, opts, errors.New("max entries must not be negative")
+	}
+	if _, ok := charsets[opts.charset]; !ok {
+		return nil, opts, fmt.Errorf("unknown charset %q", opts.charset)
+	}
+	if _, ok := sortLess[opts.sortBy]; !ok {
+		return nil, opts, fmt.Errorf("unknown sort mode %q", opts.sortBy)
+	}
+	if opts.dupes && !opts.printFiles {
+		return nil, opts, errors.New("--dupes needs -f, duplicates are looked up among files")
+	}
+	switch opts.errors {
+	case errorsInline, errorsCollect, errorsFail:
+	default:
+		return nil, opts, fmt.Errorf("unknown error policy %q", opts.errors)
+	}
+	return paths, opts, nil
+}
+
+// options holds everything that can be tuned from the command line
+type options struct {
+	printFiles bool
+	format     string
+	depth      int
+	include    patterns
+	exclude    patterns
+	minSize    sizeFlag
+	maxSize    sizeFlag
+	newerThan  timeFlag
+	olderThan  timeFlag
+	prune      bool
+	gitignore  bool
+	dirSizes   bool
+	human      bool
+	summary    bool
+	follow     bool
+	errors     string
+	workers    int
+	sortBy     string
+	dirsFirst  bool
+	reverse    bool
+	showPerms  bool
+	showOwner  bool
+	showMtime  bool
+	dupes      bool
+	maxEntries int
+	charset    string
+
+	// ownerWidth is not a flag, it is measured before printing
+	ownerWidth int
+	// noSizes is set for trees built from path lists, sizes are unknown there
+	noSizes bool
+}
+
+// needTotals reports whether directories below the depth limit
+// have to be read anyway to get correct subtree totals; prune needs
+// the file counts to tell whether such a directory is empty and sorting
+// by size needs the sizes of directories that are not expanded
+func (opts options) needTotals() bool {
+	return opts.dirSizes || opts.summary || opts.prune || opts.sortBy == sortSize
+}
+
 func dirTree(output io.Writer, path string, printFiles bool) error {
-	return printDir(output, path, printFiles, "")
+	return writeTree(output, path, options{printFiles: printFiles, format: formatText})
 }
 
-func printDir(output io.Writer, path string, printFiles bool, prePath string) error {
-	dirItems, err := ioutil.ReadDir(path)
+// dirTreeFS is dirTree for any filesystem, e.g. an archive or fstest.MapFS
+func dirTreeFS(output io.Writer, fsys fs.FS, printFiles bool) error {
+	return writeTreeFS(output, fsys, ".", options{printFiles: printFiles, format: formatText})
+}
+
+// writeTree renders path, which is a directory, an archive,
+// or "-" for a list of paths on stdin
+func writeTree(output io.Writer, path string, opts options) error {
+	if path == "-" {
+		fsys, err := readPathList(os.Stdin)
+		if err != nil {
+			return err
+		}
+		opts.noSizes = true
+		return writeTreeFS(output, fsys, ".", opts)
+	}
+
+	fsys, closeFS, err := openFS(path, opts.dupes)
 	if err != nil {
 		return err
 	}
+	defer closeFS()
+	return writeTreeFS(output, fsys, filepath.Base(path), opts)
+}
+
+// writeTreeFS prints the tree even if some directories failed to read,
+// the error is returned afterwards so the caller knows it is incomplete
+func writeTreeFS(output io.Writer, fsys fs.FS, name string, opts options) error {
+	root, err := readTree(fsys, name, opts)
+	if root == nil {
+		return err
+	}
+	var dupes []*dupGroup
+	if opts.dupes {
+		dupes = findDuplicates(fsys, root)
+	}
+	if renderErr := renderTree(output, root, opts); renderErr != nil {
+		return renderErr
+	}
+	if opts.dupes && opts.format == formatText {
+		printDuplicates(output, dupes, opts)
+	}
+	return err
+}
 
-	var items []os.FileInfo
-	for _, item := range dirItems {
-		if item.IsDir() {
-			items = append(items, item)
-		} else if printFiles {
-			items = append(items, item)
+func renderTree(output io.Writer, root *node, opts options) error {
+	switch opts.format {
+	case formatText:
+		if opts.showOwner {
+			opts.ownerWidth = maxOwnerWidth(root)
+		}
+		printDir(output, root, opts, "")
+		if opts.summary {
+			printSummary(output, root, opts)
 		}
+		return nil
+	case formatJSON:
+		return writeJSON(output, root)
+	case formatXML:
+		return writeXML(output, root)
+	case formatHTML:
+		return writeHTML(output, root, opts)
+	case formatMarkdown:
+		return writeMarkdown(output, root, opts)
+	default:
+		return fmt.Errorf("unknown format %q", opts.format)
+	}
+}
+
+// printDir prints the children of dir; with maxEntries set the rest
+// of a long directory is folded into a final "and K more" line
+func printDir(output io.Writer, dir *node, opts options, prePath string) {
+	chars := opts.chars()
+	items, hidden := dir.Children, 0
+	if opts.maxEntries > 0 && len(items) > opts.maxEntries {
+		items, hidden = items[:opts.maxEntries], len(items)-opts.maxEntries
 	}
 
 	for index, item := range items {
 		var childPrefix, prefix string
-		if index == len(items)-1 {
-			prefix, childPrefix = "└───", "\t"
+		if index == len(items)-1 && hidden == 0 {
+			prefix, childPrefix = chars.last, chars.space
 		} else {
-			prefix, childPrefix = "├───", "│\t"
+			prefix, childPrefix = chars.middle, chars.pipe
 		}
 
-		var size string
-		if item.Size() == 0 {
-			size = "empty"
-		} else {
-			size = strconv.Itoa(int(item.Size())) + "b"
+		fmt.Fprintln(output, prePath+prefix+columns(item, opts)+label(item, opts))
+		if item.isDir() {
+			printDir(output, item, opts, prePath+childPrefix)
 		}
+	}
+	if hidden > 0 {
+		fmt.Fprintln(output, prePath+chars.last+chars.ellipsis+" and "+strconv.Itoa(hidden)+" more")
+	}
+}
 
-		if item.IsDir() {
-			fmt.Fprintln(output, prePath+prefix+item.Name())
-			printDir(output, path+string(os.PathSeparator)+item.Name(), printFiles, prePath+childPrefix)
-		} else {
-			fmt.Fprintln(output, prePath+prefix+item.Name()+" ("+size+")")
-		}
+// label is the text printed after the prefix drawing for a single entry
+func label(item *node, opts options) string {
+	before, after := labelParts(item, opts)
+	return before + item.Name + after
+}
+
+// labelParts returns what goes around the entry name, so renderers
+// that decorate the name itself can keep the same annotations
+func labelParts(item *node, opts options) (string, string) {
+	var before, after string
+	if item.Diff != "" {
+		before = diffMarks[item.Diff] + " "
+	}
+	if item.Target != "" {
+		after = " -> " + item.Target
 	}
 
-	return nil
+	switch {
+	case item.Err != "":
+		after += " [error: " + item.Err + "]"
+	case item.Cycle:
+		after += " [recursive, not followed]"
+	case item.Type == typeLink:
+	case opts.noSizes && item.Diff != diffChanged:
+	case item.isDir() && opts.dirSizes:
+		after += " (" + formatSize(item.Size, opts.human) + ", " + plural(item.Files, "file", "files") + ")"
+	case item.isDir():
+	case item.Diff == diffChanged:
+		after += " (" + formatSize(item.OldSize, opts.human) + " -> " + formatSize(item.Size, opts.human) + ")"
+	case item.Dup != 0:
+		after += " (" + formatSize(item.Size, opts.human) + ") [dup " + strconv.Itoa(item.Dup) + "]"
+	default:
+		after += " (" + formatSize(item.Size, opts.human) + ")"
+	}
+	return before, after
 }
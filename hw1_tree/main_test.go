@@ -1,8 +1,18 @@
 package main
 
 import (
+	"archive/tar"
+	"archive/zip"
 	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
 )
 
 const testFullResult = `├───project
@@ -73,3 +83,835 @@ func TestTreeDir(t *testing.T) {
 		t.Errorf("test for OK Failed - results not match\nGot:\n%v\nExpected:\n%v", result, testDirResult)
 	}
 }
+
+const testJSONResult = `{
+  "name": "project",
+  "type": "dir",
+  "size": 70391,
+  "files": 2,
+  "children": [
+    {
+      "name": "file.txt",
+      "type": "file",
+      "size": 19
+    },
+    {
+      "name": "gopher.png",
+      "type": "file",
+      "size": 70372
+    }
+  ]
+}
+`
+
+func TestTreeJSON(t *testing.T) {
+	out := new(bytes.Buffer)
+	err := writeTree(out, "testdata/project", options{printFiles: true, format: formatJSON})
+	if err != nil {
+		t.Errorf("test for OK Failed - error")
+	}
+	result := out.String()
+	if result != testJSONResult {
+		t.Errorf("test for OK Failed - results not match\nGot:\n%v\nExpected:\n%v", result, testJSONResult)
+	}
+}
+
+const testXMLResult = `<?xml version="1.0" encoding="UTF-8"?>
+<node name="zline" type="dir" size="140744" files="4">
+  <node name="lorem" type="dir" size="140744" files="3">
+    <node name="ipsum" type="dir" size="70372" files="1"></node>
+  </node>
+</node>
+`
+
+func TestTreeXML(t *testing.T) {
+	out := new(bytes.Buffer)
+	err := writeTree(out, "testdata/zline", options{format: formatXML})
+	if err != nil {
+		t.Errorf("test for OK Failed - error")
+	}
+	result := out.String()
+	if result != testXMLResult {
+		t.Errorf("test for OK Failed - results not match\nGot:\n%v\nExpected:\n%v", result, testXMLResult)
+	}
+}
+
+const testFilterResult = `├───project
+│	└───file.txt (19b)
+├───static
+│	├───a_lorem
+│	│	└───dolor.txt (empty)
+│	└───z_lorem
+│		└───dolor.txt (empty)
+└───zline
+	└───lorem
+		└───dolor.txt (empty)
+`
+
+func TestTreeFilter(t *testing.T) {
+	out := new(bytes.Buffer)
+	opts := options{
+		printFiles: true,
+		format:     formatText,
+		include:    patterns{"*.txt"},
+		exclude:    patterns{"empty.txt", "zzfile.txt"},
+		prune:      true,
+	}
+	err := writeTree(out, "testdata", opts)
+	if err != nil {
+		t.Errorf("test for OK Failed - error")
+	}
+	result := out.String()
+	if result != testFilterResult {
+		t.Errorf("test for OK Failed - results not match\nGot:\n%v\nExpected:\n%v", result, testFilterResult)
+	}
+}
+
+const testDepthResult = `├───project
+├───static
+│	├───a_lorem
+│	├───html
+│	├───js
+│	└───z_lorem
+└───zline
+	└───lorem
+`
+
+func TestTreeDepth(t *testing.T) {
+	paths, opts, err := parseArgs([]string{"testdata", "-L", "2", "--prune", "--exclude", "*.css"}, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out := new(bytes.Buffer)
+	err = writeTree(out, paths[0], opts)
+	if err != nil {
+		t.Errorf("test for OK Failed - error")
+	}
+	result := out.String()
+	if result != testDepthResult {
+		t.Errorf("test for OK Failed - results not match\nGot:\n%v\nExpected:\n%v", result, testDepthResult)
+	}
+}
+
+// writeFiles creates a temporary directory with the given files,
+// paths are slash separated and relative to the directory
+func writeFiles(t *testing.T, files map[string]string) string {
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+const testGitignoreResult = `├───.gitignore (38b)
+├───keep.log (empty)
+├───main.go (empty)
+└───sub
+	├───.gitignore (14b)
+	├───main.go (empty)
+	└───root_only.txt (empty)
+`
+
+func TestTreeGitignore(t *testing.T) {
+	root := writeFiles(t, map[string]string{
+		".gitignore":        "*.log\nbuild/\n!keep.log\n/root_only.txt\n",
+		"a.log":             "",
+		"keep.log":          "",
+		"root_only.txt":     "",
+		"main.go":           "",
+		"build/out.bin":     "",
+		".git/HEAD":         "",
+		"sub/.gitignore":    "*.go\n!main.go\n",
+		"sub/debug.log":     "",
+		"sub/main.go":       "",
+		"sub/util.go":       "",
+		"sub/root_only.txt": "",
+	})
+	out := new(bytes.Buffer)
+	err := writeTree(out, root, options{printFiles: true, format: formatText, gitignore: true})
+	if err != nil {
+		t.Errorf("test for OK Failed - error")
+	}
+	result := out.String()
+	if result != testGitignoreResult {
+		t.Errorf("test for OK Failed - results not match\nGot:\n%v\nExpected:\n%v", result, testGitignoreResult)
+	}
+}
+
+const testSizesResult = `├───a_lorem (137.4K, 3 files)
+│	└───ipsum (68.7K, 1 file)
+├───css (28b, 1 file)
+├───html (57b, 1 file)
+├───js (10b, 1 file)
+└───z_lorem (137.4K, 3 files)
+	└───ipsum (68.7K, 1 file)
+
+7 directories, 10 files, total 275.0K
+`
+
+func TestTreeSizes(t *testing.T) {
+	out := new(bytes.Buffer)
+	err := writeTree(out, "testdata/static", options{format: formatText, dirSizes: true, human: true, summary: true})
+	if err != nil {
+		t.Errorf("test for OK Failed - error")
+	}
+	result := out.String()
+	if result != testSizesResult {
+		t.Errorf("test for OK Failed - results not match\nGot:\n%v\nExpected:\n%v", result, testSizesResult)
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	cases := []struct {
+		size   int64
+		human  bool
+		expect string
+	}{
+		{0, true, "empty"},
+		{512, false, "512b"},
+		{512, true, "512b"},
+		{70372, false, "70372b"},
+		{70372, true, "68.7K"},
+		{5 << 20, true, "5.0M"},
+		{3 << 30, true, "3.0G"},
+	}
+	for _, c := range cases {
+		if result := formatSize(c.size, c.human); result != c.expect {
+			t.Errorf("formatSize(%d, %v) = %q, expected %q", c.size, c.human, result, c.expect)
+		}
+	}
+}
+
+const testSymlinkResult = `└───a
+	├───b
+	│	└───up -> .. [recursive, not followed]
+	├───f.txt (3b)
+	├───link.txt -> f.txt (3b)
+	└───to_b -> b
+		└───up -> .. [recursive, not followed]
+`
+
+func TestTreeSymlink(t *testing.T) {
+	root := writeFiles(t, map[string]string{"a/f.txt": "hi\n"})
+	if err := os.Mkdir(filepath.Join(root, "a", "b"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	links := map[string]string{"a/b/up": "..", "a/link.txt": "f.txt", "a/to_b": "b"}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(root, filepath.FromSlash(name))); err != nil {
+			t.Skip("symlinks are not supported:", err)
+		}
+	}
+
+	out := new(bytes.Buffer)
+	err := writeTree(out, root, options{printFiles: true, format: formatText, follow: true})
+	if err != nil {
+		t.Errorf("test for OK Failed - error")
+	}
+	result := out.String()
+	if result != testSymlinkResult {
+		t.Errorf("test for OK Failed - results not match\nGot:\n%v\nExpected:\n%v", result, testSymlinkResult)
+	}
+
+	out.Reset()
+	err = writeTree(out, root, options{format: formatText})
+	if err != nil {
+		t.Errorf("test for OK Failed - error")
+	}
+	expected := "└───a\n\t├───b\n\t│\t└───up -> ..\n\t└───to_b -> b\n"
+	if out.String() != expected {
+		t.Errorf("test for OK Failed - results not match\nGot:\n%v\nExpected:\n%v", out.String(), expected)
+	}
+
+	// a link that is not followed shows its own permissions, not the target's
+	out.Reset()
+	err = writeTree(out, root, options{format: formatText, showPerms: true})
+	if err != nil {
+		t.Errorf("test for OK Failed - error")
+	}
+	for _, line := range []string{"[Lrwxrwxrwx]  up -> ..\n", "[Lrwxrwxrwx]  to_b -> b\n"} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("test for OK Failed - results not match\nGot:\n%v\nExpected line:\n%v", out.String(), line)
+		}
+	}
+}
+
+const testFSResult = `├───docs
+│	└───readme.md (5b)
+└───src
+	├───lib
+	│	└───util.go (empty)
+	└───main.go (12b)
+`
+
+func TestTreeFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"src/main.go":     {Data: []byte("package main")},
+		"src/lib/util.go": {},
+		"docs/readme.md":  {Data: []byte("hello")},
+	}
+	out := new(bytes.Buffer)
+	err := dirTreeFS(out, fsys, true)
+	if err != nil {
+		t.Errorf("test for OK Failed - error")
+	}
+	result := out.String()
+	if result != testFSResult {
+		t.Errorf("test for OK Failed - results not match\nGot:\n%v\nExpected:\n%v", result, testFSResult)
+	}
+}
+
+// writeArchives packs testdata/zline into a zip and a tar.gz file
+func writeArchives(t *testing.T) []string {
+	dir := t.TempDir()
+	zipPath := filepath.Join(dir, "zline.zip")
+	tarPath := filepath.Join(dir, "zline.tar.gz")
+
+	zipFile, err := os.Create(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zipWriter := zip.NewWriter(zipFile)
+	if err := zipWriter.AddFS(os.DirFS("testdata/zline")); err != nil {
+		t.Fatal(err)
+	}
+	zipWriter.Close()
+	zipFile.Close()
+
+	tarFile, err := os.Create(tarPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gzWriter := gzip.NewWriter(tarFile)
+	tarWriter := tar.NewWriter(gzWriter)
+	if err := tarWriter.AddFS(os.DirFS("testdata/zline")); err != nil {
+		t.Fatal(err)
+	}
+	tarWriter.Close()
+	gzWriter.Close()
+	tarFile.Close()
+
+	return []string{zipPath, tarPath}
+}
+
+const testArchiveResult = `├───empty.txt (empty)
+└───lorem
+	├───dolor.txt (empty)
+	├───gopher.png (70372b)
+	└───ipsum
+		└───gopher.png (70372b)
+`
+
+func TestTreeArchive(t *testing.T) {
+	for _, path := range writeArchives(t) {
+		out := new(bytes.Buffer)
+		err := dirTree(out, path, true)
+		if err != nil {
+			t.Errorf("[%s] test for OK Failed - error: %v", path, err)
+		}
+		result := out.String()
+		if result != testArchiveResult {
+			t.Errorf("[%s] test for OK Failed - results not match\nGot:\n%v\nExpected:\n%v", path, result, testArchiveResult)
+		}
+	}
+}
+
+const testTarEntriesResult = `├───copy.txt (5b) [dup 1]
+├───fifo (empty)
+├───hard.txt -> orig.txt
+└───orig.txt (5b) [dup 1]
+
+[dup 1] 2 files of 5b: copy.txt, orig.txt
+1 duplicate group, reclaimable 5b
+`
+
+func TestTreeTarEntries(t *testing.T) {
+	buf := new(bytes.Buffer)
+	tarWriter := tar.NewWriter(buf)
+	headers := []*tar.Header{
+		{Name: "orig.txt", Typeflag: tar.TypeReg, Mode: 0644, Size: 5},
+		{Name: "hard.txt", Typeflag: tar.TypeLink, Mode: 0644, Linkname: "orig.txt"},
+		{Name: "fifo", Typeflag: tar.TypeFifo, Mode: 0644},
+		{Name: "copy.txt", Typeflag: tar.TypeReg, Mode: 0644, Size: 5},
+	}
+	for _, header := range headers {
+		if err := tarWriter.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if header.Size > 0 {
+			tarWriter.Write([]byte("hello"))
+		}
+	}
+	tarWriter.Close()
+
+	// a plain listing keeps no file bodies
+	fsys, err := readTar(bytes.NewReader(buf.Bytes()), false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if data := fsys.(*memFS).entries["orig.txt"].data; data != nil {
+		t.Errorf("file body loaded for a listing: %q", data)
+	}
+
+	fsys, err = readTar(bytes.NewReader(buf.Bytes()), true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out := new(bytes.Buffer)
+	err = writeTreeFS(out, fsys, ".", options{printFiles: true, format: formatText, dupes: true})
+	if err != nil {
+		t.Errorf("test for OK Failed - error")
+	}
+	result := out.String()
+	if result != testTarEntriesResult {
+		t.Errorf("test for OK Failed - results not match\nGot:\n%v\nExpected:\n%v", result, testTarEntriesResult)
+	}
+}
+
+// brokenFS fails to list the directories named in broken
+type brokenFS struct {
+	fstest.MapFS
+	broken map[string]bool
+}
+
+func (b brokenFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if b.broken[name] {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrPermission}
+	}
+	return b.MapFS.ReadDir(name)
+}
+
+func TestTreeErrors(t *testing.T) {
+	fsys := brokenFS{
+		MapFS: fstest.MapFS{
+			"a/secret/key.txt": {},
+			"a/open.txt":       {},
+			"b/secret/key.txt": {},
+		},
+		broken: map[string]bool{"a/secret": true, "b/secret": true},
+	}
+
+	out := new(bytes.Buffer)
+	err := writeTreeFS(out, fsys, ".", options{printFiles: true, format: formatText, errors: errorsInline})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	expected := "├───a\n│\t├───open.txt (empty)\n│\t└───secret [error: permission denied]\n└───b\n\t└───secret [error: permission denied]\n"
+	if out.String() != expected {
+		t.Errorf("inline results not match\nGot:\n%v\nExpected:\n%v", out.String(), expected)
+	}
+
+	out.Reset()
+	err = writeTreeFS(out, fsys, ".", options{printFiles: true, format: formatText, errors: errorsCollect})
+	incomplete, ok := err.(*treeError)
+	if !ok || len(incomplete.errs) != 2 {
+		t.Errorf("expected 2 collected errors, got %v", err)
+	}
+	if !strings.Contains(out.String(), "└───secret\n") {
+		t.Errorf("tree should be printed before the errors are reported, got:\n%v", out.String())
+	}
+
+	// a directory that could not be read is not known to be empty
+	out.Reset()
+	err = writeTreeFS(out, fsys, ".", options{printFiles: true, format: formatText, errors: errorsCollect, prune: true})
+	if _, ok := err.(*treeError); !ok {
+		t.Errorf("expected collected errors, got %v", err)
+	}
+	expected = "├───a\n│\t├───open.txt (empty)\n│\t└───secret\n└───b\n\t└───secret\n"
+	if out.String() != expected {
+		t.Errorf("pruned results not match\nGot:\n%v\nExpected:\n%v", out.String(), expected)
+	}
+
+	out.Reset()
+	err = writeTreeFS(out, fsys, ".", options{printFiles: true, format: formatText, errors: errorsFail})
+	if !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("expected permission error, got %v", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("nothing should be printed in fail mode, got:\n%v", out.String())
+	}
+}
+
+func TestTreeWorkers(t *testing.T) {
+	for _, workers := range []int{2, 4, 16} {
+		out := new(bytes.Buffer)
+		err := writeTree(out, "testdata", options{printFiles: true, format: formatText, workers: workers})
+		if err != nil {
+			t.Errorf("[%d workers] test for OK Failed - error", workers)
+		}
+		result := out.String()
+		if result != testFullResult {
+			t.Errorf("[%d workers] test for OK Failed - results not match\nGot:\n%v\nExpected:\n%v", workers, result, testFullResult)
+		}
+	}
+}
+
+func TestTreeSort(t *testing.T) {
+	day := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"a.txt":     {Data: []byte("1"), Mode: 0644, ModTime: day},
+		"b.go":      {Data: []byte("333"), Mode: 0600, ModTime: day.Add(2 * time.Hour)},
+		"c.md":      {Data: []byte("22"), Mode: 0644, ModTime: day.Add(time.Hour)},
+		"dir/x.txt": {Data: []byte("4444"), Mode: 0644, ModTime: day},
+	}
+	cases := []struct {
+		opts   options
+		expect string
+	}{
+		{
+			options{sortBy: sortSize},
+			"├───dir\n│\t└───x.txt (4b)\n├───b.go (3b)\n├───c.md (2b)\n└───a.txt (1b)\n",
+		},
+		{
+			// dir is not expanded but still sorted by its total size
+			options{sortBy: sortSize, depth: 1},
+			"├───dir\n├───b.go (3b)\n├───c.md (2b)\n└───a.txt (1b)\n",
+		},
+		{
+			options{sortBy: sortMtime, dirsFirst: true, reverse: true},
+			"├───dir\n│\t└───x.txt (4b)\n├───a.txt (1b)\n├───c.md (2b)\n└───b.go (3b)\n",
+		},
+		{
+			options{sortBy: sortType, dirsFirst: true},
+			"├───dir\n│\t└───x.txt (4b)\n├───b.go (3b)\n├───c.md (2b)\n└───a.txt (1b)\n",
+		},
+		{
+			options{sortBy: sortName, include: patterns{"*.go", "*.md"}, showPerms: true, showMtime: true},
+			"├───[-rw------- 2020-01-01 14:00]  b.go (3b)\n" +
+				"├───[-rw-r--r-- 2020-01-01 13:00]  c.md (2b)\n" +
+				"└───[dr-xr-xr-x                 ]  dir\n",
+		},
+	}
+	for i, c := range cases {
+		c.opts.printFiles = true
+		c.opts.format = formatText
+		out := new(bytes.Buffer)
+		err := writeTreeFS(out, fsys, ".", c.opts)
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %v", i, err)
+		}
+		if out.String() != c.expect {
+			t.Errorf("[%d] results not match\nGot:\n%v\nExpected:\n%v", i, out.String(), c.expect)
+		}
+	}
+}
+
+const testDiffResult = `├───[-] css
+│	└───[-] body.css (28b)
+├───empty.txt (empty)
+├───[+] extra.txt (3b)
+├───[~] gopher.png (70372b -> 5b)
+├───html
+│	└───[~] index.html (57b -> empty)
+└───js
+	├───[-] site.js (10b)
+	└───[+] site.min.js (4b)
+`
+
+func TestTreeDiff(t *testing.T) {
+	oldFS := fstest.MapFS{
+		"css/body.css":    {Data: make([]byte, 28)},
+		"empty.txt":       {},
+		"gopher.png":      {Data: make([]byte, 70372)},
+		"html/index.html": {Data: make([]byte, 57)},
+		"js/site.js":      {Data: make([]byte, 10)},
+	}
+	newDir := writeFiles(t, map[string]string{
+		"empty.txt":       "",
+		"extra.txt":       "new",
+		"gopher.png":      "small",
+		"html/index.html": "",
+		"js/site.min.js":  "min!",
+	})
+
+	snapshot := new(bytes.Buffer)
+	err := writeTreeFS(snapshot, oldFS, "old", options{printFiles: true, format: formatJSON, sortBy: sortSize})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	snapshotPath := filepath.Join(t.TempDir(), "old.json")
+	if err := os.WriteFile(snapshotPath, snapshot.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out := new(bytes.Buffer)
+	err = writeDiff(out, snapshotPath, newDir, options{printFiles: true, format: formatText})
+	if err != nil {
+		t.Errorf("test for OK Failed - error")
+	}
+	result := out.String()
+	if result != testDiffResult {
+		t.Errorf("test for OK Failed - results not match\nGot:\n%v\nExpected:\n%v", result, testDiffResult)
+	}
+}
+
+const testDupesResult = `├───a.txt (4b) [dup 1]
+├───b.txt (4b)
+├───c
+│	├───a_copy.txt (4b) [dup 1]
+│	└───b_copy.txt (4b) [dup 2]
+├───d.txt (4b) [dup 2]
+├───e.txt (empty)
+└───f.txt (empty)
+
+[dup 1] 2 files of 4b: a.txt, c/a_copy.txt
+[dup 2] 2 files of 4b: c/b_copy.txt, d.txt
+2 duplicate groups, reclaimable 8b
+`
+
+const testDupesFollowResult = `├───a
+│	└───b
+│		├───x.txt (3b) [dup 1]
+│		└───y.txt (3b) [dup 1]
+└───c
+	└───toa -> ../a
+		└───b
+			├───x.txt (3b)
+			└───y.txt (3b)
+
+[dup 1] 2 files of 3b: a/b/x.txt, a/b/y.txt
+1 duplicate group, reclaimable 3b
+`
+
+func TestTreeDupes(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.txt":        {Data: []byte("aaaa")},
+		"b.txt":        {Data: []byte("bbbb")},
+		"c/a_copy.txt": {Data: []byte("aaaa")},
+		"c/b_copy.txt": {Data: []byte("dddd")},
+		"d.txt":        {Data: []byte("dddd")},
+		"e.txt":        {},
+		"f.txt":        {},
+	}
+	out := new(bytes.Buffer)
+	err := writeTreeFS(out, fsys, ".", options{printFiles: true, format: formatText, dupes: true})
+	if err != nil {
+		t.Errorf("test for OK Failed - error")
+	}
+	result := out.String()
+	if result != testDupesResult {
+		t.Errorf("test for OK Failed - results not match\nGot:\n%v\nExpected:\n%v", result, testDupesResult)
+	}
+
+	out.Reset()
+	err = writeTreeFS(out, fstest.MapFS{"a.txt": {Data: []byte("a")}}, ".", options{printFiles: true, format: formatText, dupes: true})
+	if err != nil {
+		t.Errorf("test for OK Failed - error")
+	}
+	expected := "└───a.txt (1b)\n\n0 duplicate groups, reclaimable 0b\n"
+	if out.String() != expected {
+		t.Errorf("test for OK Failed - results not match\nGot:\n%v\nExpected:\n%v", out.String(), expected)
+	}
+
+	if _, _, err := parseArgs([]string{".", "--dupes"}, 1); err == nil {
+		t.Errorf("expected an error for --dupes without -f")
+	}
+
+	// a file reached again through a followed link is not its own duplicate
+	root := writeFiles(t, map[string]string{"a/b/x.txt": "hi\n", "a/b/y.txt": "hi\n"})
+	if err := os.Mkdir(filepath.Join(root, "c"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join("..", "a"), filepath.Join(root, "c", "toa")); err != nil {
+		t.Skip("symlinks are not supported:", err)
+	}
+	out.Reset()
+	err = writeTree(out, root, options{printFiles: true, format: formatText, dupes: true, follow: true})
+	if err != nil {
+		t.Errorf("test for OK Failed - error")
+	}
+	if out.String() != testDupesFollowResult {
+		t.Errorf("test for OK Failed - results not match\nGot:\n%v\nExpected:\n%v", out.String(), testDupesFollowResult)
+	}
+}
+
+const testMarkdownResult = `- [empty.txt](empty.txt) (empty)
+- **lorem/**
+  - [dolor.txt](lorem/dolor.txt) (empty)
+  - [gopher.png](lorem/gopher.png) (70372b)
+  - **ipsum/**
+    - [gopher.png](lorem/ipsum/gopher.png) (70372b)
+`
+
+func TestTreeMarkdown(t *testing.T) {
+	out := new(bytes.Buffer)
+	err := writeTree(out, "testdata/zline", options{printFiles: true, format: formatMarkdown})
+	if err != nil {
+		t.Errorf("test for OK Failed - error")
+	}
+	result := out.String()
+	if result != testMarkdownResult {
+		t.Errorf("test for OK Failed - results not match\nGot:\n%v\nExpected:\n%v", result, testMarkdownResult)
+	}
+}
+
+const testHTMLResult = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>site</title>
+</head>
+<body>
+<details open><summary>site</summary>
+<ul>
+<li><a href="a%20&amp;%20b.html">a &amp; b.html</a> (3b)</li>
+<li><details><summary>img</summary>
+<ul>
+<li><a href="img/%3Clogo%3E.png">&lt;logo&gt;.png</a> (empty)</li>
+</ul>
+</details></li>
+</ul>
+</details>
+</body>
+</html>
+`
+
+func TestTreeHTML(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a & b.html":     {Data: []byte("<p>")},
+		"img/<logo>.png": {},
+	}
+	out := new(bytes.Buffer)
+	err := writeTreeFS(out, fsys, "site", options{printFiles: true, format: formatHTML})
+	if err != nil {
+		t.Errorf("test for OK Failed - error")
+	}
+	result := out.String()
+	if result != testHTMLResult {
+		t.Errorf("test for OK Failed - results not match\nGot:\n%v\nExpected:\n%v", result, testHTMLResult)
+	}
+}
+
+func TestTreeSizeTimeFilter(t *testing.T) {
+	now := time.Now()
+	fsys := fstest.MapFS{
+		"logs/old.log":   {Data: make([]byte, 2048), ModTime: now.Add(-30 * 24 * time.Hour)},
+		"logs/new.log":   {Data: make([]byte, 4096), ModTime: now.Add(-time.Hour)},
+		"logs/tiny.log":  {Data: make([]byte, 10), ModTime: now.Add(-time.Hour)},
+		"media/big.mp4":  {Data: make([]byte, 8192), ModTime: now.Add(-2 * time.Hour)},
+		"media/cold.mp4": {Data: make([]byte, 8192), ModTime: now.Add(-60 * 24 * time.Hour)},
+		"notes/a.txt":    {Data: make([]byte, 5), ModTime: now},
+	}
+	_, opts, err := parseArgs([]string{".", "-f", "--min-size", "1K", "--max-size=4K", "--newer-than", "1w", "--prune"}, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out := new(bytes.Buffer)
+	err = writeTreeFS(out, fsys, ".", opts)
+	if err != nil {
+		t.Errorf("test for OK Failed - error")
+	}
+	expected := "└───logs\n\t└───new.log (4096b)\n"
+	if out.String() != expected {
+		t.Errorf("test for OK Failed - results not match\nGot:\n%v\nExpected:\n%v", out.String(), expected)
+	}
+
+	_, opts, err = parseArgs([]string{".", "-f", "--older-than", "2020-01-01", "--prune"}, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out.Reset()
+	writeTreeFS(out, fsys, ".", opts)
+	if out.Len() != 0 {
+		t.Errorf("nothing is older than 2020, got:\n%v", out.String())
+	}
+}
+
+func TestParseSizeAndAge(t *testing.T) {
+	sizes := map[string]int64{"512": 512, "10K": 10 << 10, "1.5M": 3 << 19, "2gb": 2 << 30, "100b": 100}
+	for value, expect := range sizes {
+		var size sizeFlag
+		if err := size.Set(value); err != nil || int64(size) != expect {
+			t.Errorf("size %q: got %d, %v, expected %d", value, size, err, expect)
+		}
+	}
+	for _, value := range []string{"", "K", "1x", "-5M"} {
+		var size sizeFlag
+		if err := size.Set(value); err == nil {
+			t.Errorf("size %q: expected error", value)
+		}
+	}
+
+	ages := map[string]time.Duration{"36h": 36 * time.Hour, "7d": 7 * 24 * time.Hour, "2w": 14 * 24 * time.Hour, "1.5d": 36 * time.Hour}
+	for value, expect := range ages {
+		if age, err := parseAge(value); err != nil || age != expect {
+			t.Errorf("age %q: got %v, %v, expected %v", value, age, err, expect)
+		}
+	}
+	if _, err := parseAge("soon"); err == nil {
+		t.Errorf("age %q: expected error", "soon")
+	}
+}
+
+const testPathListResult = `├───cmd
+│	└───tool
+│		└───main.go
+├───docs
+├───go.mod
+└───pkg
+	└───util
+		├───util.go
+		└───util_test.go
+`
+
+func TestTreePathList(t *testing.T) {
+	input := strings.NewReader("./go.mod\r\npkg/util/util.go\n\ncmd\n/cmd/tool/main.go\ndocs/\npkg/util/util_test.go\ngo.mod\n")
+	fsys, err := readPathList(input)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out := new(bytes.Buffer)
+	err = writeTreeFS(out, fsys, ".", options{printFiles: true, format: formatText, noSizes: true})
+	if err != nil {
+		t.Errorf("test for OK Failed - error")
+	}
+	result := out.String()
+	if result != testPathListResult {
+		t.Errorf("test for OK Failed - results not match\nGot:\n%v\nExpected:\n%v", result, testPathListResult)
+	}
+}
+
+const testTruncateResult = "|-- project\n" +
+	"|\t|-- file.txt (19b)\n" +
+	"|\t`-- gopher.png (70372b)\n" +
+	"|-- static\n" +
+	"|\t|-- a_lorem\n" +
+	"|\t|\t|-- dolor.txt (empty)\n" +
+	"|\t|\t|-- gopher.png (70372b)\n" +
+	"|\t|\t`-- ... and 1 more\n" +
+	"|\t|-- css\n" +
+	"|\t|\t`-- body.css (28b)\n" +
+	"|\t`-- ... and 4 more\n" +
+	"`-- ... and 2 more\n"
+
+func TestTreeTruncate(t *testing.T) {
+	paths, opts, err := parseArgs([]string{"testdata", "-f", "--max-entries", "2", "--charset", "ascii"}, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out := new(bytes.Buffer)
+	err = writeTree(out, paths[0], opts)
+	if err != nil {
+		t.Errorf("test for OK Failed - error")
+	}
+	result := out.String()
+	if result != testTruncateResult {
+		t.Errorf("test for OK Failed - results not match\nGot:\n%v\nExpected:\n%v", result, testTruncateResult)
+	}
+
+	out.Reset()
+	err = writeTree(out, "testdata/zline", options{printFiles: true, format: formatText, maxEntries: 1})
+	if err != nil {
+		t.Errorf("test for OK Failed - error")
+	}
+	expected := "├───empty.txt (empty)\n└───… and 1 more\n"
+	if out.String() != expected {
+		t.Errorf("test for OK Failed - results not match\nGot:\n%v\nExpected:\n%v", out.String(), expected)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// memFS is a read-only filesystem kept in memory, it backs archives that
+// cannot be browsed in place. Symlinks are stored but never resolved.
+type memFS struct {
+	entries map[string]*memEntry
+}
+
+// memEntry is both the fs.FileInfo and the fs.DirEntry of a memFS file
+type memEntry struct {
+	name     string
+	mode     fs.FileMode
+	size     int64
+	modTime  time.Time
+	data     []byte
+	target   string
+	children []*memEntry
+}
+
+func newMemFS() *memFS {
+	root := &memEntry{name: ".", mode: fs.ModeDir | 0555}
+	return &memFS{entries: map[string]*memEntry{".": root}}
+}
+
+// add stores a file, missing parent directories are created on the way.
+// Adding an existing name updates it, which lets explicit directory
+// headers come after the files inside them.
+func (m *memFS) add(name string, mode fs.FileMode, size int64, modTime time.Time, data []byte, target string) {
+	name = path.Clean(strings.TrimPrefix(name, "/"))
+	if !fs.ValidPath(name) || name == "." {
+		return
+	}
+	entry, ok := m.entries[name]
+	if !ok {
+		entry = &memEntry{name: path.Base(name)}
+		m.entries[name] = entry
+		parent := m.mkdir(path.Dir(name))
+		parent.children = append(parent.children, entry)
+	}
+	entry.mode, entry.size, entry.modTime, entry.data, entry.target = mode, size, modTime, data, target
+}
+
+// mkdir returns the directory called name, creating it and its parents.
+// An entry stored as a file turns into a directory once something is put
+// inside it, path lists do not say which names are directories.
+func (m *memFS) mkdir(name string) *memEntry {
+	if entry, ok := m.entries[name]; ok {
+		if !entry.IsDir() {
+			entry.mode = fs.ModeDir | 0555
+		}
+		return entry
+	}
+	entry := &memEntry{name: path.Base(name), mode: fs.ModeDir | 0555}
+	m.entries[name] = entry
+	parent := m.mkdir(path.Dir(name))
+	parent.children = append(parent.children, entry)
+	return entry
+}
+
+func (m *memFS) lookup(op, name string) (*memEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	entry, ok := m.entries[name]
+	if !ok {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return entry, nil
+}
+
+func (m *memFS) Open(name string) (fs.File, error) {
+	entry, err := m.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return &memFile{memEntry: entry, reader: bytes.NewReader(entry.data)}, nil
+}
+
+func (m *memFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entry, err := m.lookup("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !entry.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	list := make([]fs.DirEntry, 0, len(entry.children))
+	for _, child := range entry.children {
+		list = append(list, child)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name() < list[j].Name() })
+	return list, nil
+}
+
+func (m *memFS) Stat(name string) (fs.FileInfo, error) {
+	return m.Lstat(name)
+}
+
+func (m *memFS) Lstat(name string) (fs.FileInfo, error) {
+	entry, err := m.lookup("lstat", name)
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (m *memFS) ReadLink(name string) (string, error) {
+	entry, err := m.lookup("readlink", name)
+	if err != nil {
+		return "", err
+	}
+	if entry.mode&fs.ModeSymlink == 0 {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	return entry.target, nil
+}
+
+func (e *memEntry) Name() string               { return e.name }
+func (e *memEntry) Size() int64                { return e.size }
+func (e *memEntry) Mode() fs.FileMode          { return e.mode }
+func (e *memEntry) ModTime() time.Time         { return e.modTime }
+func (e *memEntry) IsDir() bool                { return e.mode.IsDir() }
+func (e *memEntry) Sys() interface{}           { return nil }
+func (e *memEntry) Type() fs.FileMode          { return e.mode.Type() }
+func (e *memEntry) Info() (fs.FileInfo, error) { return e, nil }
+
+type memFile struct {
+	*memEntry
+	reader *bytes.Reader
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) {
+	return f.memEntry, nil
+}
+
+func (f *memFile) Read(b []byte) (int, error) {
+	if f.IsDir() {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: fs.ErrInvalid}
+	}
+	return f.reader.Read(b)
+}
+
+func (f *memFile) Close() error {
+	return nil
+}
@@ -0,0 +1,12 @@
+//go:build !unix
+
+package main
+
+import (
+	"io/fs"
+)
+
+// fileOwner is not supported outside unix systems
+func fileOwner(info fs.FileInfo) string {
+	return "?"
+}
@@ -0,0 +1,36 @@
+//go:build unix
+
+package main
+
+import (
+	"io/fs"
+	"os/user"
+	"strconv"
+	"sync"
+	"syscall"
+)
+
+var (
+	ownersMu sync.Mutex
+	owners   = map[uint32]string{}
+)
+
+// fileOwner returns the user name owning the file, or the numeric
+// uid when there is no such user
+func fileOwner(info fs.FileInfo) string {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "?"
+	}
+	ownersMu.Lock()
+	defer ownersMu.Unlock()
+	if name, ok := owners[stat.Uid]; ok {
+		return name
+	}
+	name := strconv.FormatUint(uint64(stat.Uid), 10)
+	if u, err := user.LookupId(name); err == nil {
+		name = u.Username
+	}
+	owners[stat.Uid] = name
+	return name
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+)
+
+// readPathList builds a filesystem from newline separated paths, as printed
+// by git ls-files, find or tar -t. Names ending with a slash or having
+// entries below them are directories, everything else is an empty file.
+func readPathList(input io.Reader) (*memFS, error) {
+	fsys := newMemFS()
+	scanner := bufio.NewScanner(input)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		name := path.Clean(strings.TrimPrefix(line, "/"))
+		if _, ok := fsys.entries[name]; ok || !fs.ValidPath(name) {
+			continue
+		}
+		if strings.HasSuffix(line, "/") {
+			fsys.mkdir(name)
+		} else {
+			fsys.add(name, 0444, 0, time.Time{}, nil, "")
+		}
+	}
+	return fsys, scanner.Err()
+}
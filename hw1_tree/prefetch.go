@@ -0,0 +1,72 @@
+package main
+
+import (
+	"io/fs"
+	"sync"
+)
+
+// listing is a directory read that may still be in flight
+type listing struct {
+	dir     string
+	done    chan struct{}
+	entries []fs.DirEntry
+	err     error
+}
+
+// prefetcher reads directories ahead of the walker with a fixed number of
+// workers. The walker still builds the tree one directory at a time, it just
+// finds the listings ready, so the output order does not depend on timing.
+type prefetcher struct {
+	fsys    fs.FS
+	jobs    chan *listing
+	mu      sync.Mutex
+	pending map[string]*listing
+}
+
+func newPrefetcher(fsys fs.FS, workers int) *prefetcher {
+	p := &prefetcher{
+		fsys:    fsys,
+		jobs:    make(chan *listing, workers),
+		pending: map[string]*listing{},
+	}
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+func (p *prefetcher) work() {
+	for l := range p.jobs {
+		l.entries, l.err = fs.ReadDir(p.fsys, l.dir)
+		close(l.done)
+	}
+}
+
+// add queues dir for reading, it blocks while all workers are busy
+// and the queue is full
+func (p *prefetcher) add(dir string) {
+	l := &listing{dir: dir, done: make(chan struct{})}
+	p.mu.Lock()
+	p.pending[dir] = l
+	p.mu.Unlock()
+	p.jobs <- l
+}
+
+// readDir waits for the prefetched listing of dir,
+// directories that were never queued are read right away
+func (p *prefetcher) readDir(dir string) ([]fs.DirEntry, error) {
+	p.mu.Lock()
+	l, ok := p.pending[dir]
+	delete(p.pending, dir)
+	p.mu.Unlock()
+	if !ok {
+		return fs.ReadDir(p.fsys, dir)
+	}
+	<-l.done
+	return l.entries, l.err
+}
+
+// close stops the workers once the queued listings are read
+func (p *prefetcher) close() {
+	close(p.jobs)
+}
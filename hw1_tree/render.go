@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// writeHTML renders a standalone page, directories are collapsible
+// <details> blocks and files link to their path relative to the root
+func writeHTML(output io.Writer, root *node, opts options) error {
+	title := html.EscapeString(root.Name)
+	fmt.Fprintf(output, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n</head>\n<body>\n", title)
+	fmt.Fprintf(output, "<details open><summary>%s</summary>\n", title)
+	writeHTMLList(output, root, opts)
+	_, err := fmt.Fprint(output, "</details>\n</body>\n</html>\n")
+	return err
+}
+
+func writeHTMLList(output io.Writer, dir *node, opts options) {
+	fmt.Fprintln(output, "<ul>")
+	for _, item := range dir.Children {
+		before, after := labelParts(item, opts)
+		name := html.EscapeString(item.Name)
+		if !item.isDir() && item.path != "" {
+			name = `<a href="` + html.EscapeString(linkPath(item.path)) + `">` + name + "</a>"
+		}
+		text := html.EscapeString(before) + name + html.EscapeString(after)
+
+		if item.isDir() && len(item.Children) > 0 {
+			fmt.Fprintf(output, "<li><details><summary>%s</summary>\n", text)
+			writeHTMLList(output, item, opts)
+			fmt.Fprintln(output, "</details></li>")
+		} else {
+			fmt.Fprintf(output, "<li>%s</li>\n", text)
+		}
+	}
+	fmt.Fprintln(output, "</ul>")
+}
+
+// writeMarkdown renders a nested list, directories are bold
+// and files link to their path relative to the root
+func writeMarkdown(output io.Writer, root *node, opts options) error {
+	writeMarkdownList(output, root, opts, "")
+	return nil
+}
+
+func writeMarkdownList(output io.Writer, dir *node, opts options, indent string) {
+	for _, item := range dir.Children {
+		before, after := labelParts(item, opts)
+		name := markdownEscaper.Replace(item.Name)
+		switch {
+		case item.isDir():
+			name = "**" + name + "/**"
+		case item.path != "":
+			name = "[" + name + "](" + linkPath(item.path) + ")"
+		}
+		fmt.Fprintln(output, indent+"- "+markdownEscaper.Replace(before)+name+markdownEscaper.Replace(after))
+		if item.isDir() {
+			writeMarkdownList(output, item, opts, indent+"  ")
+		}
+	}
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`, "<", `\<`, ">", `\>`,
+)
+
+// linkPath escapes every segment of a slash separated path for use in a URL
+func linkPath(name string) string {
+	segments := strings.Split(name, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
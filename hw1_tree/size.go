@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+var sizeUnits = []string{"K", "M", "G", "T"}
+
+// formatSize renders a size the way the tree prints it: "empty", "123b",
+// or with human set "1.5K", "20.0M" and so on
+func formatSize(size int64, human bool) string {
+	if size == 0 {
+		return "empty"
+	}
+	if !human || size < 1024 {
+		return strconv.FormatInt(size, 10) + "b"
+	}
+	value := float64(size)
+	unit := ""
+	for _, unit = range sizeUnits {
+		value /= 1024
+		if value < 1024 {
+			break
+		}
+	}
+	return fmt.Sprintf("%.1f%s", value, unit)
+}
+
+func plural(count int, one, many string) string {
+	if count == 1 {
+		return "1 " + one
+	}
+	return strconv.Itoa(count) + " " + many
+}
+
+func countDirs(dir *node) int {
+	count := 0
+	for _, item := range dir.Children {
+		if item.isDir() {
+			count += 1 + countDirs(item)
+		}
+	}
+	return count
+}
+
+// printSummary prints the GNU tree like footer, directories are counted as
+// printed while files and total size cover everything that passed the filters
+func printSummary(output io.Writer, root *node, opts options) {
+	fmt.Fprintf(output, "\n%s, %s, total %s\n",
+		plural(countDirs(root), "directory", "directories"), plural(root.Files, "file", "files"), formatSize(root.Size, opts.human))
+}
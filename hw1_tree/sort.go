@@ -0,0 +1,44 @@
+package main
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+const (
+	sortName  = "name"
+	sortSize  = "size"
+	sortMtime = "mtime"
+	sortType  = "type"
+)
+
+// sortLess holds the order of every sort mode, size and mtime put the
+// biggest and the newest first like ls does, type sorts by extension
+var sortLess = map[string]func(a, b *node) bool{
+	sortName:  func(a, b *node) bool { return a.Name < b.Name },
+	sortSize:  func(a, b *node) bool { return a.Size > b.Size },
+	sortMtime: func(a, b *node) bool { return a.modTime.After(b.modTime) },
+	sortType: func(a, b *node) bool {
+		return strings.ToLower(path.Ext(a.Name)) < strings.ToLower(path.Ext(b.Name))
+	},
+}
+
+// sortNodes orders the entries of a directory. Children come in name order
+// from ReadDir, a stable sort keeps it as the tie breaker for other modes.
+func sortNodes(nodes []*node, opts options) {
+	less, ok := sortLess[opts.sortBy]
+	if !ok {
+		less = sortLess[sortName]
+	}
+	sort.SliceStable(nodes, func(i, j int) bool {
+		a, b := nodes[i], nodes[j]
+		if opts.dirsFirst && a.isDir() != b.isDir() {
+			return a.isDir()
+		}
+		if opts.reverse {
+			return less(b, a)
+		}
+		return less(a, b)
+	})
+}
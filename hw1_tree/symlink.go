@@ -0,0 +1,33 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+)
+
+// readLink returns the target of the symlink at name together with the info
+// of the file it points to. A dangling link keeps its own info, as does
+// any link on a filesystem that cannot resolve them.
+func readLink(fsys fs.FS, name string, item fs.FileInfo) (string, fs.FileInfo) {
+	target, err := fs.ReadLink(fsys, name)
+	if err != nil {
+		return "", item
+	}
+	info, err := fs.Stat(fsys, name)
+	if err != nil {
+		return target, item
+	}
+	return target, info
+}
+
+// isAncestor reports whether dir is one of the directories we came through.
+// os.SameFile compares device and inode numbers on unix systems, so a link
+// pointing back up the tree is caught whatever path it uses.
+func isAncestor(dir fs.FileInfo, ancestors []fs.FileInfo) bool {
+	for _, ancestor := range ancestors {
+		if os.SameFile(dir, ancestor) {
+			return true
+		}
+	}
+	return false
+}
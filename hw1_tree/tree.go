@@ -0,0 +1,226 @@
+package main
+
+import (
+	"io/fs"
+	"path"
+	"time"
+)
+
+const (
+	typeDir  = "dir"
+	typeFile = "file"
+	typeLink = "link"
+)
+
+// node is a single entry of the tree, shared by every output format
+type node struct {
+	Name     string  `json:"name" xml:"name,attr"`
+	Type     string  `json:"type" xml:"type,attr"`
+	Size     int64   `json:"size" xml:"size,attr"`
+	Files    int     `json:"files,omitempty" xml:"files,attr,omitempty"`
+	Target   string  `json:"target,omitempty" xml:"target,attr,omitempty"`
+	Cycle    bool    `json:"cycle,omitempty" xml:"cycle,attr,omitempty"`
+	Err      string  `json:"error,omitempty" xml:"error,attr,omitempty"`
+	Diff     string  `json:"diff,omitempty" xml:"diff,attr,omitempty"`
+	OldSize  int64   `json:"oldSize,omitempty" xml:"oldSize,attr,omitempty"`
+	Dup      int     `json:"dup,omitempty" xml:"dup,attr,omitempty"`
+	Children []*node `json:"children,omitempty" xml:"node"`
+
+	path    string
+	info    fs.FileInfo
+	mode    fs.FileMode
+	modTime time.Time
+	owner   string
+	failed  bool // the directory could not be read, whatever the error policy
+}
+
+func (n *node) isDir() bool {
+	return n.Type == typeDir
+}
+
+// walker reads a directory tree into nodes according to the options
+type walker struct {
+	fsys     fs.FS
+	opts     options
+	errs     []error
+	prefetch *prefetcher
+}
+
+// readTree reads the whole fsys, the root node is called name.
+// With the collect error policy both the tree and a *treeError
+// are returned when some directories could not be read.
+func readTree(fsys fs.FS, name string, opts options) (*node, error) {
+	w := &walker{fsys: fsys, opts: opts}
+	if opts.workers > 1 {
+		w.prefetch = newPrefetcher(fsys, opts.workers)
+		defer w.prefetch.close()
+	}
+	root := &node{Name: name, Type: typeDir}
+	var ancestors []fs.FileInfo
+	if opts.follow {
+		info, err := fs.Stat(fsys, ".")
+		if err != nil {
+			return nil, err
+		}
+		ancestors = append(ancestors, info)
+	}
+	if err := w.readDir(root, ".", 1, nil, ancestors); err != nil {
+		return nil, err
+	}
+	if len(w.errs) > 0 {
+		return root, &treeError{errs: w.errs}
+	}
+	return root, nil
+}
+
+// dirItem is a directory entry that passed the filters,
+// with symlinks already resolved to what they point to
+type dirItem struct {
+	info   fs.FileInfo
+	link   fs.FileInfo // the symlink itself, for the columns of a bare link
+	path   string
+	target string
+}
+
+// isLink reports whether the item is printed as a bare link
+// instead of the file or directory it points to
+func (it dirItem) isLink(opts options) bool {
+	return it.target != "" && (!opts.follow || it.info.Mode()&fs.ModeSymlink != 0)
+}
+
+// listItems reads dir and drops everything hidden by the filters
+func (w *walker) listItems(dir string, ignores ignoreStack) ([]dirItem, error) {
+	dirEntries, err := w.list(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]dirItem, 0, len(dirEntries))
+	for _, entry := range dirEntries {
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		item := dirItem{info: info, path: path.Join(dir, info.Name())}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			item.link = info
+			item.target, item.info = readLink(w.fsys, item.path, info)
+		}
+		if !visible(item.info, w.opts) {
+			continue
+		}
+		if w.opts.gitignore && (info.Name() == ".git" || ignores.ignored(item.path, item.info.IsDir())) {
+			continue
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// list reads dir, taking the result from the prefetcher when there is one
+func (w *walker) list(dir string) ([]fs.DirEntry, error) {
+	if w.prefetch != nil {
+		return w.prefetch.readDir(dir)
+	}
+	return fs.ReadDir(w.fsys, dir)
+}
+
+// readDir fills parent with the entries of dir; depth is the level
+// of these entries, starting from 1 for the root directory content.
+// Sizes and file counts of the subtree are summed into parent, files
+// hidden by printFiles still count as long as they pass the filters.
+// ancestors holds the directories on the way down from the root and
+// is only tracked when symlinks are followed.
+func (w *walker) readDir(parent *node, dir string, depth int, ignores ignoreStack, ancestors []fs.FileInfo) error {
+	opts := w.opts
+	if opts.gitignore {
+		ignores = ignores.push(w.fsys, dir)
+	}
+	items, err := w.listItems(dir, ignores)
+	if err != nil {
+		return err
+	}
+
+	expand := opts.depth == 0 || depth < opts.depth
+	descend := func(item dirItem) bool {
+		return item.info.IsDir() && !item.isLink(opts) && (expand || opts.needTotals()) &&
+			!(item.target != "" && isAncestor(item.info, ancestors))
+	}
+	if w.prefetch != nil {
+		for _, item := range items {
+			if descend(item) {
+				w.prefetch.add(item.path)
+			}
+		}
+	}
+
+	for _, item := range items {
+		info := item.info
+		if item.isLink(opts) {
+			parent.Files++
+			if opts.printFiles || info.IsDir() {
+				parent.Children = append(parent.Children, w.newNode(item, typeLink))
+			}
+		} else if info.IsDir() {
+			child := w.newNode(item, typeDir)
+			if item.target != "" && isAncestor(info, ancestors) {
+				child.Cycle = true
+				parent.Children = append(parent.Children, child)
+				continue
+			}
+			if descend(item) {
+				childAncestors := ancestors
+				if opts.follow {
+					childAncestors = append(ancestors[:len(ancestors):len(ancestors)], info)
+				}
+				if err := w.readDir(child, item.path, depth+1, ignores, childAncestors); err != nil {
+					if err := w.dirError(child, err); err != nil {
+						return err
+					}
+				}
+				parent.Size += child.Size
+				parent.Files += child.Files
+				if !expand {
+					child.Children = nil
+				}
+				// without -f a directory of files has no children but is not empty
+				if opts.prune && child.Files == 0 && len(child.Children) == 0 && !child.failed {
+					continue
+				}
+			}
+			parent.Children = append(parent.Children, child)
+		} else {
+			parent.Size += info.Size()
+			parent.Files++
+			if opts.printFiles {
+				child := w.newNode(item, typeFile)
+				child.Size = info.Size()
+				parent.Children = append(parent.Children, child)
+			}
+		}
+	}
+
+	sortNodes(parent.Children, opts)
+	return nil
+}
+
+func (w *walker) newNode(item dirItem, typ string) *node {
+	// a bare link shows its own metadata, whatever it points to
+	meta := item.info
+	if typ == typeLink && item.link != nil {
+		meta = item.link
+	}
+	n := &node{
+		Name:    item.info.Name(),
+		Type:    typ,
+		Target:  item.target,
+		path:    item.path,
+		info:    item.info,
+		mode:    meta.Mode(),
+		modTime: meta.ModTime(),
+	}
+	if w.opts.showOwner {
+		n.owner = fileOwner(meta)
+	}
+	return n
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+)
+
+// CacheStats counts how calls to a Cache were answered
+type CacheStats struct {
+	Hits   int64 // served from the cache
+	Misses int64 // computed
+	Shared int64 // waited for an identical call already in flight
+}
+
+// Cache is a bounded LRU of signer results. Concurrent calls with the
+// same key are computed once, the others wait for that result.
+type Cache struct {
+	mu       sync.Mutex
+	size     int
+	lru      list.List // of *cacheEntry, most recently used in front
+	items    map[string]*list.Element
+	inFlight map[string]*flight
+	stats    CacheStats
+}
+
+type cacheEntry struct {
+	key, value string
+}
+
+type flight struct {
+	done  chan struct{}
+	value string
+	ok    bool // false if compute panicked
+}
+
+// NewCache keeps up to size results, size < 1 only deduplicates calls in flight
+func NewCache(size int) *Cache {
+	return &Cache{
+		size:     size,
+		items:    make(map[string]*list.Element),
+		inFlight: make(map[string]*flight),
+	}
+}
+
+// Get returns the cached value for key or computes it
+func (c *Cache) Get(key string, compute func() string) string {
+	c.mu.Lock()
+	if elem, ok := c.items[key]; ok {
+		c.lru.MoveToFront(elem)
+		c.stats.Hits++
+		c.mu.Unlock()
+		return elem.Value.(*cacheEntry).value
+	}
+	if f, ok := c.inFlight[key]; ok {
+		c.stats.Shared++
+		c.mu.Unlock()
+		<-f.done
+		if !f.ok {
+			return c.Get(key, compute)
+		}
+		return f.value
+	}
+	f := &flight{done: make(chan struct{})}
+	c.inFlight[key] = f
+	c.stats.Misses++
+	c.mu.Unlock()
+
+	// a panic in compute must not leave the waiters hanging,
+	// they retry on their own instead
+	defer func() {
+		c.mu.Lock()
+		delete(c.inFlight, key)
+		c.mu.Unlock()
+		close(f.done)
+	}()
+	f.value = compute()
+	f.ok = true
+
+	c.mu.Lock()
+	c.add(key, f.value)
+	c.mu.Unlock()
+	return f.value
+}
+
+func (c *Cache) add(key, value string) {
+	if c.size < 1 {
+		return
+	}
+	c.items[key] = c.lru.PushFront(&cacheEntry{key: key, value: value})
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// Len is the number of cached results
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// Stats returns the counters collected so far
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// Memoized wraps a signer function with cache, the input is the key
+func Memoized(cache *Cache, f func(data string) string) func(data string) string {
+	return func(data string) string {
+		return cache.Get(data, func() string { return f(data) })
+	}
+}
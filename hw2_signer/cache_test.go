@@ -0,0 +1,68 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	var calls int32
+	slowUpper := func(data string) string {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(50 * time.Millisecond)
+		return strings.ToUpper(data)
+	}
+	cache := NewCache(2)
+	upper := Memoized(cache, slowUpper)
+
+	// identical calls in flight are computed once
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got := upper("a"); got != "A" {
+				t.Errorf("results not match\nGot: %v\nExpected: A", got)
+			}
+		}()
+	}
+	wg.Wait()
+	if calls != 1 {
+		t.Errorf("expected 1 call, got %d", calls)
+	}
+
+	upper("b")
+	upper("a") // a is now the most recent, b gets evicted by c
+	upper("c")
+	upper("a")
+	upper("b")
+	if calls != 4 {
+		t.Errorf("expected 4 calls, got %d", calls)
+	}
+	if cache.Len() != 2 {
+		t.Errorf("expected 2 cached results, got %d", cache.Len())
+	}
+	stats := cache.Stats()
+	if stats.Misses != 4 || stats.Shared+stats.Hits != 6 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	// waiters of a panicking call compute on their own
+	flaky := NewCache(2)
+	started := make(chan struct{})
+	go func() {
+		defer func() { recover() }()
+		flaky.Get("x", func() string {
+			close(started)
+			time.Sleep(20 * time.Millisecond)
+			panic("boom")
+		})
+	}()
+	<-started
+	if got := flaky.Get("x", func() string { return "X" }); got != "X" {
+		t.Errorf("results not match\nGot: %v\nExpected: X", got)
+	}
+}
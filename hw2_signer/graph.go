@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Graph is a directed acyclic graph of jobs. Every node reads the merged
+// outputs of its inputs, its own output goes to all nodes that read it
+// unless Partition picks one of them per value. Inputs have to be declared
+// before the nodes that read them, so a Graph can not have cycles.
+type Graph struct {
+	nodes  []*graphNode
+	byName map[string]*graphNode
+	err    error
+}
+
+type graphNode struct {
+	name      string
+	job       ctxJob
+	inputs    []*graphNode
+	consumers []*graphNode
+	key       func(value interface{}) int // nil broadcasts
+	buffer    int                         // of the output and the edges to the consumers
+}
+
+// NewGraph returns an empty graph
+func NewGraph() *Graph {
+	return &Graph{byName: map[string]*graphNode{}}
+}
+
+// Stage adds a node running job on the merged outputs of inputs,
+// a node without inputs gets a closed input channel like the first job
+// of ExecutePipeline. opts.Buffer sizes the output of the node, the other
+// options belong to Parallel and are given where job is built.
+// Mistakes are reported by Run.
+func (g *Graph) Stage(name string, job ctxJob, opts StageOptions, inputs ...string) *Graph {
+	if g.err != nil {
+		return g
+	}
+	if _, ok := g.byName[name]; ok {
+		g.err = fmt.Errorf("graph: duplicate stage %q", name)
+		return g
+	}
+	node := &graphNode{name: name, job: job, buffer: opts.buffer()}
+	for i, input := range inputs {
+		from, ok := g.byName[input]
+		if !ok {
+			g.err = fmt.Errorf("graph: stage %q reads unknown stage %q", name, input)
+			return g
+		}
+		for _, seen := range inputs[:i] {
+			if seen == input {
+				g.err = fmt.Errorf("graph: stage %q reads stage %q twice", name, input)
+				return g
+			}
+		}
+		node.inputs = append(node.inputs, from)
+		from.consumers = append(from.consumers, node)
+	}
+	g.nodes = append(g.nodes, node)
+	g.byName[name] = node
+	return g
+}
+
+// Partition sends every output value of name to a single consumer,
+// the one at key(value) modulo their count in declaration order
+func (g *Graph) Partition(name string, key func(value interface{}) int) *Graph {
+	if g.err != nil {
+		return g
+	}
+	node, ok := g.byName[name]
+	if !ok {
+		g.err = fmt.Errorf("graph: partition of unknown stage %q", name)
+		return g
+	}
+	node.key = key
+	return g
+}
+
+// Run starts every stage and returns after all of them have exited,
+// with the first error. Outputs of nodes nobody reads are discarded.
+// The channels belong to the run, so a Graph may run several times at once.
+func (g *Graph) Run(ctx context.Context) error {
+	if g.err != nil {
+		return g.err
+	}
+	group := &stageGroup{}
+	group.ctx, group.cancel = context.WithCancel(ctx)
+	defer group.cancel()
+
+	// edges holds the channels from every node to its consumers
+	edges := make(map[*graphNode][]chan interface{}, len(g.nodes))
+	for _, node := range g.nodes {
+		edges[node] = make([]chan interface{}, len(node.consumers))
+		for i := range edges[node] {
+			edges[node][i] = make(chan interface{}, node.buffer)
+		}
+	}
+	for _, node := range g.nodes {
+		out := startStage(group, merge(group, node, edges), node.job, node.buffer)
+		group.wg.Add(1)
+		go func(node *graphNode) {
+			defer group.wg.Done()
+			node.distribute(group, out, edges[node])
+		}(node)
+	}
+	group.wg.Wait()
+	return group.firstErr
+}
+
+// merge fans in the edges leading to node
+func merge(group *stageGroup, node *graphNode, edges map[*graphNode][]chan interface{}) chan interface{} {
+	var incoming []chan interface{}
+	for _, from := range node.inputs {
+		for i, consumer := range from.consumers {
+			if consumer == node {
+				incoming = append(incoming, edges[from][i])
+			}
+		}
+	}
+	if len(incoming) == 1 {
+		return incoming[0]
+	}
+
+	merged := make(chan interface{}, defaultBuffer)
+	wg := &sync.WaitGroup{}
+	for _, edge := range incoming {
+		wg.Add(1)
+		group.wg.Add(1)
+		go func(edge chan interface{}) {
+			defer group.wg.Done()
+			defer wg.Done()
+			for value := range edge {
+				// after a failure keep reading so the sender can finish
+				_ = send(group.ctx, merged, value)
+			}
+		}(edge)
+	}
+	go func() {
+		wg.Wait()
+		close(merged)
+	}()
+	return merged
+}
+
+// distribute passes the stage output to the consumers and closes
+// their edges once the stage is done
+func (n *graphNode) distribute(group *stageGroup, out chan interface{}, edges []chan interface{}) {
+	defer func() {
+		for _, edge := range edges {
+			close(edge)
+		}
+	}()
+	ctx := group.ctx
+	for value := range out {
+		if len(edges) == 0 || ctx.Err() != nil {
+			continue
+		}
+		if n.key != nil {
+			i, err := n.partition(value, len(edges))
+			if err != nil {
+				group.fail(err)
+				continue
+			}
+			_ = send(ctx, edges[i], value)
+			continue
+		}
+		for _, edge := range edges {
+			_ = send(ctx, edge, value)
+		}
+	}
+}
+
+// partition picks one of count edges for value, a panic of the key is an error
+func (n *graphNode) partition(value interface{}, count int) (i int, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = recovered(r)
+		}
+	}()
+	i = n.key(value) % count
+	if i < 0 {
+		i += count
+	}
+	return i, nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestGraph(t *testing.T) {
+	source := func(ctx context.Context, in, out chan interface{}) error {
+		for i := 1; i <= 6; i++ {
+			if err := send(ctx, out, interface{}(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	tag := func(label string) ctxJob {
+		return func(ctx context.Context, in, out chan interface{}) error {
+			for value := range in {
+				if err := send(ctx, out, interface{}(label+strconv.Itoa(value.(int)))); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+	var (
+		mu     sync.Mutex
+		result []string
+	)
+	collect := func(ctx context.Context, in, out chan interface{}) error {
+		for value := range in {
+			mu.Lock()
+			result = append(result, value.(string))
+			mu.Unlock()
+		}
+		return nil
+	}
+
+	// source is partitioned into odd and even, all is broadcast its copy,
+	// sink merges the three branches
+	err := NewGraph().
+		Stage("source", source, StageOptions{}).
+		Stage("even", tag("e"), StageOptions{}, "source").
+		Stage("odd", tag("o"), StageOptions{}, "source").
+		Partition("source", func(value interface{}) int { return value.(int) % 2 }).
+		Stage("numbers", source, StageOptions{}).
+		Stage("all", tag("a"), StageOptions{}, "numbers").
+		Stage("sink", collect, StageOptions{}, "even", "odd", "all").
+		Run(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sort.Strings(result)
+	expected := "a1 a2 a3 a4 a5 a6 e2 e4 e6 o1 o3 o5"
+	if strings.Join(result, " ") != expected {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", strings.Join(result, " "), expected)
+	}
+
+	errStop := errors.New("stop")
+	err = NewGraph().
+		Stage("source", source, StageOptions{}).
+		Stage("a", tag("a"), StageOptions{}, "source").
+		Stage("b", func(ctx context.Context, in, out chan interface{}) error {
+			<-in
+			return errStop
+		}, StageOptions{}, "source").
+		Stage("sink", collect, StageOptions{}, "a", "b").
+		Run(context.Background())
+	if !errors.Is(err, errStop) {
+		t.Errorf("expected %v, got %v", errStop, err)
+	}
+
+	err = NewGraph().Stage("a", source, StageOptions{}).Stage("b", collect, StageOptions{}, "c").Run(context.Background())
+	if err == nil {
+		t.Errorf("expected an error for an unknown input")
+	}
+	err = NewGraph().Stage("a", source, StageOptions{}).Stage("a", source, StageOptions{}).Run(context.Background())
+	if err == nil {
+		t.Errorf("expected an error for a duplicate stage")
+	}
+	err = NewGraph().Stage("a", source, StageOptions{}).Stage("b", collect, StageOptions{}, "a", "a").Run(context.Background())
+	if err == nil {
+		t.Errorf("expected an error for a duplicate input")
+	}
+
+	// the same graph runs twice at once, each run with its own channels
+	// and an unbuffered output for the tagged values
+	var (
+		countMu sync.Mutex
+		count   int
+	)
+	countValues := func(ctx context.Context, in, out chan interface{}) error {
+		for range in {
+			countMu.Lock()
+			count++
+			countMu.Unlock()
+		}
+		return nil
+	}
+	graph := NewGraph().
+		Stage("source", source, StageOptions{}).
+		Stage("tag", tag("t"), StageOptions{Buffer: -1}, "source").
+		Stage("sink", countValues, StageOptions{}, "tag")
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := graph.Run(context.Background()); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if count != 12 {
+		t.Errorf("expected 12 values from two runs, got %d", count)
+	}
+
+	// a panicking partition key fails the graph instead of the process
+	err = NewGraph().
+		Stage("source", source, StageOptions{}).
+		Stage("even", tag("e"), StageOptions{}, "source").
+		Stage("odd", tag("o"), StageOptions{}, "source").
+		Partition("source", func(value interface{}) int { return value.(int) / (value.(int) - 3) }).
+		Run(context.Background())
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) {
+		t.Errorf("expected a PanicError, got %v", err)
+	}
+}
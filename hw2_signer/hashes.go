@@ -0,0 +1,128 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"sort"
+	"sync"
+)
+
+var (
+	signersMu sync.RWMutex
+	// the DataSigner functions are looked up at call time so that overriding
+	// them keeps working for the registry
+	signers = map[string]func(data string) string{
+		"crc32": func(data string) string { return DataSignerCrc32(data) },
+		"md5":   func(data string) string { return signMd5(data) },
+		"sha256": func(data string) string {
+			return fmt.Sprintf("%x", sha256.Sum256([]byte(data+DataSignerSalt)))
+		},
+		"xxhash": func(data string) string {
+			return fmt.Sprintf("%016x", xxhash64([]byte(data+DataSignerSalt)))
+		},
+		"hmac": func(data string) string {
+			mac := hmac.New(sha256.New, []byte(DataSignerSalt))
+			mac.Write([]byte(data))
+			return fmt.Sprintf("%x", mac.Sum(nil))
+		},
+	}
+)
+
+// RegisterSigner adds or replaces a signer function available to recipes
+func RegisterSigner(name string, f func(data string) string) {
+	signersMu.Lock()
+	defer signersMu.Unlock()
+	signers[name] = f
+}
+
+// Signer returns a registered signer function by name
+func Signer(name string) (func(data string) string, bool) {
+	signersMu.RLock()
+	defer signersMu.RUnlock()
+	f, ok := signers[name]
+	return f, ok
+}
+
+// SignerNames lists the registered signers in alphabetical order
+func SignerNames() []string {
+	signersMu.RLock()
+	defer signersMu.RUnlock()
+	names := make([]string, 0, len(signers))
+	for name := range signers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// the primes are variables so that the seed arithmetic may wrap around
+var (
+	xxPrime1 uint64 = 11400714785074694791
+	xxPrime2 uint64 = 14029467366897019727
+	xxPrime3 uint64 = 1609587929392839161
+	xxPrime4 uint64 = 9650029242287828579
+	xxPrime5 uint64 = 2870177450012600261
+)
+
+// xxhash64 is XXH64 with seed 0
+func xxhash64(b []byte) uint64 {
+	n := len(b)
+	var h uint64
+	if n >= 32 {
+		v1 := xxPrime1 + xxPrime2
+		v2 := xxPrime2
+		v3 := uint64(0)
+		v4 := -xxPrime1
+		for len(b) >= 32 {
+			v1 = xxRound(v1, binary.LittleEndian.Uint64(b[0:8]))
+			v2 = xxRound(v2, binary.LittleEndian.Uint64(b[8:16]))
+			v3 = xxRound(v3, binary.LittleEndian.Uint64(b[16:24]))
+			v4 = xxRound(v4, binary.LittleEndian.Uint64(b[24:32]))
+			b = b[32:]
+		}
+		h = bits.RotateLeft64(v1, 1) + bits.RotateLeft64(v2, 7) +
+			bits.RotateLeft64(v3, 12) + bits.RotateLeft64(v4, 18)
+		h = xxMerge(h, v1)
+		h = xxMerge(h, v2)
+		h = xxMerge(h, v3)
+		h = xxMerge(h, v4)
+	} else {
+		h = xxPrime5
+	}
+	h += uint64(n)
+
+	for ; len(b) >= 8; b = b[8:] {
+		h ^= xxRound(0, binary.LittleEndian.Uint64(b[:8]))
+		h = bits.RotateLeft64(h, 27)*xxPrime1 + xxPrime4
+	}
+	if len(b) >= 4 {
+		h ^= uint64(binary.LittleEndian.Uint32(b[:4])) * xxPrime1
+		h = bits.RotateLeft64(h, 23)*xxPrime2 + xxPrime3
+		b = b[4:]
+	}
+	for _, c := range b {
+		h ^= uint64(c) * xxPrime5
+		h = bits.RotateLeft64(h, 11) * xxPrime1
+	}
+
+	h ^= h >> 33
+	h *= xxPrime2
+	h ^= h >> 29
+	h *= xxPrime3
+	h ^= h >> 32
+	return h
+}
+
+func xxRound(acc, input uint64) uint64 {
+	acc += input * xxPrime2
+	acc = bits.RotateLeft64(acc, 31)
+	return acc * xxPrime1
+}
+
+func xxMerge(acc, v uint64) uint64 {
+	acc ^= xxRound(0, v)
+	return acc*xxPrime1 + xxPrime4
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter decides when a signer call may run. Waiting callers are served
+// in arrival order, every Acquire that returned nil needs a Release.
+type Limiter interface {
+	Acquire(ctx context.Context) error
+	Release()
+	Stats() LimiterStats
+}
+
+// LimiterStats shows how long callers waited for a limiter
+type LimiterStats struct {
+	Calls     int64 // successful Acquire calls
+	Waited    int64 // calls that could not run at once
+	TotalWait time.Duration
+	MaxWait   time.Duration
+}
+
+// AvgWait is the mean wait over all successful calls
+func (s LimiterStats) AvgWait() time.Duration {
+	if s.Calls == 0 {
+		return 0
+	}
+	return s.TotalWait / time.Duration(s.Calls)
+}
+
+type waitStats struct {
+	mu    sync.Mutex
+	stats LimiterStats
+}
+
+func (w *waitStats) record(wait time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.stats.Calls++
+	if wait > 0 {
+		w.stats.Waited++
+		w.stats.TotalWait += wait
+	}
+	if wait > w.stats.MaxWait {
+		w.stats.MaxWait = wait
+	}
+}
+
+func (w *waitStats) Stats() LimiterStats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.stats
+}
+
+// semaphore lets at most size callers run at once, the rest queue up
+type semaphore struct {
+	waitStats
+	mu      sync.Mutex
+	size    int
+	running int
+	waiters list.List // of chan struct{}, closed when the turn comes
+}
+
+// NewSemaphore bounds the number of concurrent calls to n,
+// NewSemaphore(1) is the md5 "one at a time" restriction
+func NewSemaphore(n int) Limiter {
+	if n < 1 {
+		n = 1
+	}
+	return &semaphore{size: n}
+}
+
+func (s *semaphore) Acquire(ctx context.Context) error {
+	s.mu.Lock()
+	if s.running < s.size && s.waiters.Len() == 0 {
+		s.running++
+		s.mu.Unlock()
+		s.record(0)
+		return nil
+	}
+	ready := make(chan struct{})
+	elem := s.waiters.PushBack(ready)
+	s.mu.Unlock()
+
+	start := time.Now()
+	select {
+	case <-ready:
+		s.record(time.Since(start))
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		select {
+		case <-ready:
+			// the turn came together with the cancel, pass it on
+			s.mu.Unlock()
+			s.Release()
+		default:
+			s.waiters.Remove(elem)
+			s.mu.Unlock()
+		}
+		return ctx.Err()
+	}
+}
+
+func (s *semaphore) Release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.running--
+	for s.running < s.size && s.waiters.Len() > 0 {
+		front := s.waiters.Front()
+		s.waiters.Remove(front)
+		s.running++
+		close(front.Value.(chan struct{}))
+	}
+}
+
+// tokenBucket allows rate calls per second with bursts of up to burst calls.
+// Every caller reserves the next free slot, so the queue is first come first served.
+type tokenBucket struct {
+	waitStats
+	mu       sync.Mutex
+	interval time.Duration
+	burst    time.Duration // interval * burst
+	next     time.Time     // when the last reserved token is paid off
+}
+
+// NewTokenBucket limits calls to rate per second, burst of them may run back to back
+func NewTokenBucket(rate float64, burst int) Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	interval := time.Duration(float64(time.Second) / rate)
+	return &tokenBucket{
+		interval: interval,
+		burst:    interval * time.Duration(burst),
+	}
+}
+
+func (b *tokenBucket) Acquire(ctx context.Context) error {
+	b.mu.Lock()
+	now := time.Now()
+	if b.next.Before(now) {
+		b.next = now
+	}
+	b.next = b.next.Add(b.interval)
+	reserved := b.next
+	wait := reserved.Add(-b.burst).Sub(now)
+	b.mu.Unlock()
+
+	if wait <= 0 {
+		b.record(0)
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		b.record(wait)
+		return nil
+	case <-ctx.Done():
+		b.mu.Lock()
+		// give the slot back unless somebody queued behind it
+		if b.next.Equal(reserved) {
+			b.next = b.next.Add(-b.interval)
+		}
+		b.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// Release is a no-op, tokens come back with time
+func (b *tokenBucket) Release() {}
+
+// chain takes every limiter in turn, for example a rate and a concurrency bound
+type chain []Limiter
+
+// Chain combines limiters, a call runs once it got through all of them
+func Chain(limiters ...Limiter) Limiter {
+	return chain(limiters)
+}
+
+func (c chain) Acquire(ctx context.Context) error {
+	for i, l := range c {
+		if err := l.Acquire(ctx); err != nil {
+			for j := i - 1; j >= 0; j-- {
+				c[j].Release()
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (c chain) Release() {
+	for i := len(c) - 1; i >= 0; i-- {
+		c[i].Release()
+	}
+}
+
+// Stats sums the waits of the chained limiters, Calls are the ones that got through all
+func (c chain) Stats() LimiterStats {
+	var total LimiterStats
+	for _, l := range c {
+		s := l.Stats()
+		total.Waited += s.Waited
+		total.TotalWait += s.TotalWait
+		if s.MaxWait > total.MaxWait {
+			total.MaxWait = s.MaxWait
+		}
+		total.Calls = s.Calls
+	}
+	return total
+}
+
+// Limited wraps a signer function so that every call goes through limiter
+func Limited(limiter Limiter, f func(data string) string) func(data string) string {
+	return func(data string) string {
+		// without a context Acquire can not fail
+		_ = limiter.Acquire(context.Background())
+		defer limiter.Release()
+		return f(data)
+	}
+}
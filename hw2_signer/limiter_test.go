@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSemaphoreLimiter(t *testing.T) {
+	sem := NewSemaphore(1)
+	if err := sem.Acquire(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// waiters queue up in arrival order
+	var (
+		mu    sync.Mutex
+		order []string
+	)
+	wg := &sync.WaitGroup{}
+	for _, name := range []string{"a", "b", "c"} {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			call := Limited(sem, func(data string) string {
+				mu.Lock()
+				order = append(order, data)
+				mu.Unlock()
+				return data
+			})
+			call(name)
+		}(name)
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := sem.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+
+	sem.Release()
+	wg.Wait()
+	if strings.Join(order, "") != "abc" {
+		t.Errorf("results not match\nGot: %v\nExpected: abc", strings.Join(order, ""))
+	}
+
+	stats := sem.Stats()
+	if stats.Calls != 4 || stats.Waited != 3 || stats.MaxWait < 20*time.Millisecond {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestTokenBucketLimiter(t *testing.T) {
+	bucket := NewTokenBucket(100, 2)
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		if err := bucket.Acquire(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		bucket.Release()
+	}
+	// two calls go at once, the other four wait 10ms each
+	if elapsed := time.Since(start); elapsed < 35*time.Millisecond {
+		t.Errorf("token bucket too fast: %v", elapsed)
+	}
+	stats := bucket.Stats()
+	if stats.Calls != 6 || stats.Waited != 4 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	limiter := Chain(NewTokenBucket(1, 1), NewSemaphore(2))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_ = limiter.Acquire(context.Background())
+	limiter.Release()
+	if err := limiter.Acquire(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+}
+
+func TestSharedMd5Limit(t *testing.T) {
+	var contended int32
+	lock := OverheatLock
+	defer func() { OverheatLock = lock }()
+	OverheatLock = func() {
+		for !atomic.CompareAndSwapUint32(&dataSignerOverheat, 0, 1) {
+			atomic.AddInt32(&contended, 1)
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	before := Md5LimiterStats()
+	// two pipelines at once still share the md5 limit
+	wg := &sync.WaitGroup{}
+	for p := 0; p < 2; p++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			in := make(chan int, 3)
+			in <- 1
+			in <- 2
+			in <- 3
+			close(in)
+			if err := NewPipeline(NewSingleHashStage(StageOptions{})).Run(context.Background(), in, nil); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if contended != 0 {
+		t.Errorf("md5 overheated %d times", contended)
+	}
+	stats := Md5LimiterStats()
+	if stats.Calls-before.Calls != 6 || stats.Waited == before.Waited {
+		t.Errorf("unexpected md5 limiter stats: %+v, before %+v", stats, before)
+	}
+}
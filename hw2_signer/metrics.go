@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+)
+
+// latencyBounds are the upper bounds of the histogram buckets,
+// the last bucket takes everything slower
+var latencyBounds = []time.Duration{
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+	10 * time.Second,
+}
+
+// Histogram counts latencies in the latencyBounds buckets
+type Histogram struct {
+	Counts []int64 // len(latencyBounds)+1 buckets
+	Count  int64
+	Sum    time.Duration
+	Max    time.Duration
+}
+
+func (h *Histogram) add(d time.Duration) {
+	if h.Counts == nil {
+		h.Counts = make([]int64, len(latencyBounds)+1)
+	}
+	i := 0
+	for i < len(latencyBounds) && d >= latencyBounds[i] {
+		i++
+	}
+	h.Counts[i]++
+	h.Count++
+	h.Sum += d
+	if d > h.Max {
+		h.Max = d
+	}
+}
+
+// Mean is the average latency
+func (h Histogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+// StageSnapshot is what Observe saw of one stage
+type StageSnapshot struct {
+	Name          string
+	In, Out       int64
+	QueueDepth    int // values waiting in the input channel at the last read
+	MaxQueueDepth int
+	Goroutines    int64 // the stage itself and the workers of Parallel
+	MaxGoroutines int64
+	// Latency is the time of every fn call for Parallel stages, for other
+	// stages it runs from the oldest input since the previous output to each
+	// output, so an aggregating stage gives one value per result
+	Latency Histogram
+}
+
+// MetricsSnapshot is a copy of all stage metrics at one moment
+type MetricsSnapshot struct {
+	Stages     []StageSnapshot
+	Goroutines int // of the whole process
+}
+
+// Metrics collects the numbers of every stage wrapped by Observe
+type Metrics struct {
+	mu     sync.Mutex
+	stages []*stageMetrics
+}
+
+type stageMetrics struct {
+	name          string
+	in, out       int64
+	goroutines    int64
+	maxGoroutines int64
+
+	mu            sync.Mutex
+	queueDepth    int
+	maxQueueDepth int
+	oldest        time.Time // first input since the last output, zero if none
+	perItem       bool      // Parallel reports the latency of each item itself
+	latency       Histogram
+}
+
+// NewMetrics returns an empty collector
+func NewMetrics() *Metrics {
+	return &Metrics{}
+}
+
+func (m *Metrics) stage(name string) *stageMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, s := range m.stages {
+		if s.name == name {
+			return s
+		}
+	}
+	s := &stageMetrics{name: name}
+	m.stages = append(m.stages, s)
+	return s
+}
+
+func (s *stageMetrics) received(depth int) {
+	atomic.AddInt64(&s.in, 1)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queueDepth = depth
+	if depth > s.maxQueueDepth {
+		s.maxQueueDepth = depth
+	}
+	if s.oldest.IsZero() {
+		s.oldest = time.Now()
+	}
+}
+
+func (s *stageMetrics) sent() {
+	atomic.AddInt64(&s.out, 1)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// a source stage has no input to measure against
+	if !s.perItem && !s.oldest.IsZero() {
+		s.latency.add(time.Since(s.oldest))
+	}
+	s.oldest = time.Time{}
+}
+
+// measured records the latency of one item, it is a no-op on nil
+// so that stages can call it whether they are observed or not
+func (s *stageMetrics) measured(d time.Duration) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.perItem = true
+	s.latency.add(d)
+}
+
+// goroutine counts a goroutine of the stage starting or exiting, no-op on nil
+func (s *stageMetrics) goroutine(delta int64) {
+	if s == nil {
+		return
+	}
+	n := atomic.AddInt64(&s.goroutines, delta)
+	for {
+		max := atomic.LoadInt64(&s.maxGoroutines)
+		if n <= max || atomic.CompareAndSwapInt64(&s.maxGoroutines, max, n) {
+			return
+		}
+	}
+}
+
+type metricsKey struct{}
+
+// observed returns the metrics of the stage running with ctx, nil if it is not observed
+func observed(ctx context.Context) *stageMetrics {
+	s, _ := ctx.Value(metricsKey{}).(*stageMetrics)
+	return s
+}
+
+// Observe wraps stage so that m sees its traffic under name.
+// The values pass through extra unbuffered channels, so the stage
+// still sees the depth of the real input in its metrics.
+func Observe[In, Out any](m *Metrics, name string, stage Stage[In, Out]) Stage[In, Out] {
+	s := m.stage(name)
+	return func(ctx context.Context, in chan In, out chan Out) error {
+		s.goroutine(1)
+		defer s.goroutine(-1)
+		ctx = context.WithValue(ctx, metricsKey{}, s)
+
+		stageIn := make(chan In)
+		stageOut := make(chan Out)
+		wg := &sync.WaitGroup{}
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			defer close(stageIn)
+			for {
+				value, ok, err := recv(ctx, in)
+				if err != nil || !ok {
+					return
+				}
+				s.received(len(in))
+				if send(ctx, stageIn, value) != nil {
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for value := range stageOut {
+				if send(ctx, out, value) == nil {
+					s.sent()
+				}
+			}
+		}()
+
+		// deferred so that a panic of stage, recovered by the pipeline,
+		// does not leave the forwarding goroutines behind
+		defer func() {
+			close(stageOut)
+			// let the reader finish like startStage does
+			for range stageIn {
+			}
+			wg.Wait()
+		}()
+		return stage(ctx, stageIn, stageOut)
+	}
+}
+
+// Snapshot copies the current metrics in the order the stages were observed
+func (m *Metrics) Snapshot() MetricsSnapshot {
+	m.mu.Lock()
+	stages := append([]*stageMetrics(nil), m.stages...)
+	m.mu.Unlock()
+
+	snapshot := MetricsSnapshot{Goroutines: runtime.NumGoroutine()}
+	for _, s := range stages {
+		s.mu.Lock()
+		latency := s.latency
+		latency.Counts = append([]int64(nil), s.latency.Counts...)
+		stage := StageSnapshot{
+			Name:          s.name,
+			In:            atomic.LoadInt64(&s.in),
+			Out:           atomic.LoadInt64(&s.out),
+			QueueDepth:    s.queueDepth,
+			MaxQueueDepth: s.maxQueueDepth,
+			Goroutines:    atomic.LoadInt64(&s.goroutines),
+			MaxGoroutines: atomic.LoadInt64(&s.maxGoroutines),
+			Latency:       latency,
+		}
+		s.mu.Unlock()
+		snapshot.Stages = append(snapshot.Stages, stage)
+	}
+	return snapshot
+}
+
+// Dump writes the snapshot as a table, one line per stage
+func (s MetricsSnapshot) Dump(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprint(tw, "stage\tin\tout\tqueue\tgoroutines\tmean\tmax")
+	for _, bound := range latencyBounds {
+		fmt.Fprintf(tw, "\t<%v", bound)
+	}
+	fmt.Fprintf(tw, "\t>=%v\n", latencyBounds[len(latencyBounds)-1])
+	for _, stage := range s.Stages {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d/%d\t%d/%d\t%v\t%v",
+			stage.Name, stage.In, stage.Out,
+			stage.QueueDepth, stage.MaxQueueDepth,
+			stage.Goroutines, stage.MaxGoroutines,
+			stage.Latency.Mean().Round(time.Millisecond), stage.Latency.Max.Round(time.Millisecond))
+		for i := 0; i <= len(latencyBounds); i++ {
+			count := int64(0)
+			if stage.Latency.Counts != nil {
+				count = stage.Latency.Counts[i]
+			}
+			fmt.Fprintf(tw, "\t%d", count)
+		}
+		fmt.Fprintln(tw)
+	}
+	fmt.Fprintf(tw, "goroutines: %d\n", s.Goroutines)
+	return tw.Flush()
+}
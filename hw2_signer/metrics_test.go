@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetrics(t *testing.T) {
+	m := NewMetrics()
+	slow := Parallel(func(ctx context.Context, value int) (int, error) {
+		time.Sleep(20 * time.Millisecond)
+		return value, nil
+	}, StageOptions{Workers: 2})
+	sum := func(ctx context.Context, in chan int, out chan int) error {
+		total := 0
+		for value := range in {
+			total += value
+		}
+		return send(ctx, out, total)
+	}
+
+	in := make(chan int, 6)
+	for i := 1; i <= 6; i++ {
+		in <- i
+	}
+	close(in)
+	var result int
+	p := Then(NewPipeline(Observe(m, "slow", slow)), Observe(m, "sum", Stage[int, int](sum)))
+	err := p.Run(context.Background(), in, func(value int) error {
+		result = value
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result != 21 {
+		t.Errorf("results not match\nGot: %v\nExpected: 21", result)
+	}
+
+	snapshot := m.Snapshot()
+	if len(snapshot.Stages) != 2 {
+		t.Fatalf("expected 2 stages, got %d", len(snapshot.Stages))
+	}
+	slowStage, sumStage := snapshot.Stages[0], snapshot.Stages[1]
+	if slowStage.In != 6 || slowStage.Out != 6 || sumStage.In != 6 || sumStage.Out != 1 {
+		t.Errorf("unexpected counts: %+v %+v", slowStage, sumStage)
+	}
+	// the stage goroutine and two workers
+	if slowStage.MaxGoroutines != 3 || slowStage.Goroutines != 0 {
+		t.Errorf("unexpected goroutines: %d max %d", slowStage.Goroutines, slowStage.MaxGoroutines)
+	}
+	if slowStage.MaxQueueDepth < 1 {
+		t.Errorf("expected a queue in front of the slow stage")
+	}
+	if slowStage.Latency.Count != 6 || slowStage.Latency.Counts[2] != 6 {
+		t.Errorf("expected 6 latencies between 10ms and 100ms, got %v", slowStage.Latency.Counts)
+	}
+	// sum answers all its inputs with one result
+	if sumStage.Latency.Count != 1 || sumStage.Latency.Max < 30*time.Millisecond {
+		t.Errorf("expected one latency over the whole input, got %+v", sumStage.Latency)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := snapshot.Dump(buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	lines := strings.Split(buf.String(), "\n")
+	if !strings.HasPrefix(lines[0], "stage") || !strings.HasPrefix(lines[1], "slow ") || !strings.HasPrefix(lines[2], "sum ") {
+		t.Errorf("unexpected dump:\n%s", buf.String())
+	}
+}
+
+func TestObservePanic(t *testing.T) {
+	before := runtime.NumGoroutine()
+	m := NewMetrics()
+	bad := Observe(m, "bad", Stage[int, int](func(ctx context.Context, in chan int, out chan int) error {
+		<-in
+		panic("bad record")
+	}))
+	for i := 0; i < 3; i++ {
+		in := make(chan int, 2)
+		in <- 1
+		in <- 2
+		close(in)
+		err := NewPipeline(bad).Run(context.Background(), in, nil)
+		var panicErr *PanicError
+		if !errors.As(err, &panicErr) {
+			t.Fatalf("expected a PanicError, got %v", err)
+		}
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("goroutines leaked: %d before, %d after", before, after)
+	}
+	if stage := m.Snapshot().Stages[0]; stage.In != 6 || stage.Goroutines != 0 {
+		t.Errorf("unexpected stage metrics: %+v", stage)
+	}
+}
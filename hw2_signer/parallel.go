@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Parallel builds a stage that applies fn to every item concurrently.
+// opts.Workers bounds the number of items in flight and opts.Ordered keeps
+// the output in input order. A panic of fn is an error, failed items are
+// retried by opts.Retry and then go to opts.DeadLetter if it is set,
+// otherwise the first error stops the stage.
+func Parallel[In, Out any](fn func(ctx context.Context, value In) (Out, error), opts StageOptions) Stage[In, Out] {
+	return func(ctx context.Context, in chan In, out chan Out) error {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		var (
+			once     sync.Once
+			firstErr error
+		)
+		fail := func(err error) {
+			once.Do(func() {
+				firstErr = err
+				cancel()
+			})
+		}
+
+		var sem chan struct{}
+		if opts.Workers > 0 {
+			sem = make(chan struct{}, opts.Workers)
+		}
+
+		// in ordered mode every item gets a slot, queued in input order,
+		// and a single emitter waits for the slots one by one
+		var slots chan chan Out
+		emitted := make(chan struct{})
+		if opts.Ordered {
+			queue := opts.Workers
+			if queue == 0 {
+				queue = defaultBuffer
+			}
+			slots = make(chan chan Out, queue)
+			go func() {
+				defer close(emitted)
+				for slot := range slots {
+					if result, ok := <-slot; ok {
+						if err := send(ctx, out, result); err != nil {
+							fail(err)
+						}
+					}
+				}
+			}()
+		} else {
+			close(emitted)
+		}
+
+		metrics := observed(ctx)
+		wg := &sync.WaitGroup{}
+		for {
+			value, ok, err := recv(ctx, in)
+			if err != nil {
+				fail(err)
+			}
+			if err != nil || !ok {
+				break
+			}
+			if sem != nil {
+				if err := send(ctx, sem, struct{}{}); err != nil {
+					fail(err)
+					break
+				}
+			}
+			var slot chan Out
+			if slots != nil {
+				slot = make(chan Out, 1)
+				if err := send(ctx, slots, slot); err != nil {
+					fail(err)
+					break
+				}
+			}
+
+			wg.Add(1)
+			go func(value In, slot chan Out) {
+				defer wg.Done()
+				metrics.goroutine(1)
+				defer metrics.goroutine(-1)
+				if sem != nil {
+					defer func() { <-sem }()
+				}
+				start := time.Now()
+				result, attempts, err := retry(ctx, opts.Retry, fn, value)
+				metrics.measured(time.Since(start))
+				if err != nil && opts.DeadLetter != nil && ctx.Err() == nil {
+					// the item is dropped instead of failing the stage
+					dead := DeadLetter{Value: value, Err: err, Attempts: attempts}
+					if err = send(ctx, opts.DeadLetter, dead); err == nil {
+						if slot != nil {
+							close(slot)
+						}
+						return
+					}
+				}
+				switch {
+				case err != nil:
+					fail(err)
+					if slot != nil {
+						close(slot)
+					}
+				case slot != nil:
+					slot <- result
+				default:
+					if err := send(ctx, out, result); err != nil {
+						fail(err)
+					}
+				}
+			}(value, slot)
+		}
+
+		wg.Wait()
+		if slots != nil {
+			close(slots)
+		}
+		<-emitted
+		return firstErr
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrUnexpectedType is returned by context stages instead of the
+// "type accession error" panic of the plain ones
+var ErrUnexpectedType = errors.New("unexpected input type")
+
+// Stage is a typed pipeline step. It reads in until it is closed or ctx is
+// done and the pipeline closes out once it returns. The channels are
+// bidirectional so that the untyped jobs fit in as Stage[interface{}, interface{}].
+type Stage[In, Out any] func(ctx context.Context, in chan In, out chan Out) error
+
+// ctxJob is a pipeline stage that can fail and has to stop once ctx is done
+type ctxJob = Stage[interface{}, interface{}]
+
+// defaultBuffer is the capacity of the channel after a stage
+// unless StageOptions say otherwise
+const defaultBuffer = 10
+
+// StageOptions tunes a single stage, the zero value keeps the defaults
+type StageOptions struct {
+	// Workers limits the goroutines of a Parallel stage, 0 starts one per item
+	Workers int
+	// Ordered makes a Parallel stage emit results in input order
+	Ordered bool
+	// Buffer is the capacity of the stage output channel,
+	// 0 means defaultBuffer and a negative value an unbuffered channel
+	Buffer int
+	// Retry reruns the failing items of a Parallel stage
+	Retry RetryPolicy
+	// DeadLetter receives the items of a Parallel stage that failed every
+	// attempt, the stage goes on without them. The caller has to read it.
+	DeadLetter chan DeadLetter
+}
+
+func (opts StageOptions) buffer() int {
+	switch {
+	case opts.Buffer < 0:
+		return 0
+	case opts.Buffer == 0:
+		return defaultBuffer
+	default:
+		return opts.Buffer
+	}
+}
+
+// firstOptions returns the options passed to a variadic parameter, if any
+func firstOptions(opts []StageOptions) StageOptions {
+	if len(opts) == 0 {
+		return StageOptions{}
+	}
+	return opts[0]
+}
+
+// stageGroup is shared by all stages of a running pipeline: the first
+// error cancels the context of every stage
+type stageGroup struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	once     sync.Once
+	firstErr error
+}
+
+func (g *stageGroup) fail(err error) {
+	g.once.Do(func() {
+		g.firstErr = err
+		g.cancel()
+	})
+}
+
+// stop cancels the stages that are still running once the result is
+// complete, what they return afterwards is not an error of the pipeline
+func (g *stageGroup) stop() {
+	g.once.Do(g.cancel)
+	g.cancel()
+}
+
+// Pipeline is a chain of stages turning In values into Out values,
+// it is built with NewPipeline and Then so every link is type checked
+type Pipeline[In, Out any] struct {
+	start func(g *stageGroup, in chan In) chan Out
+}
+
+// NewPipeline starts a pipeline with its first stage, opts
+// may set the buffer size of the stage output
+func NewPipeline[In, Out any](stage Stage[In, Out], opts ...StageOptions) *Pipeline[In, Out] {
+	buffer := firstOptions(opts).buffer()
+	return &Pipeline[In, Out]{
+		start: func(g *stageGroup, in chan In) chan Out {
+			return startStage(g, in, stage, buffer)
+		},
+	}
+}
+
+// Then appends a stage reading what the pipeline produces. It is a function
+// rather than a method because methods cannot have type parameters.
+func Then[In, Mid, Out any](p *Pipeline[In, Mid], stage Stage[Mid, Out], opts ...StageOptions) *Pipeline[In, Out] {
+	buffer := firstOptions(opts).buffer()
+	return &Pipeline[In, Out]{
+		start: func(g *stageGroup, in chan In) chan Out {
+			return startStage(g, p.start(g, in), stage, buffer)
+		},
+	}
+}
+
+func startStage[In, Out any](g *stageGroup, in chan In, stage Stage[In, Out], buffer int) chan Out {
+	out := make(chan Out, buffer)
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		err := runStage(g.ctx, in, out, stage)
+		if err != nil {
+			g.fail(err)
+		}
+		// the next stage sees the end of its input right away,
+		// not only once the whole upstream chain is done
+		close(out)
+		// the previous stage may still be sending, drain it so it can exit
+		for range in {
+		}
+	}()
+	return out
+}
+
+// runStage calls stage and turns its panic into a *PanicError
+func runStage[In, Out any](ctx context.Context, in chan In, out chan Out, stage Stage[In, Out]) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = recovered(r)
+		}
+	}()
+	return stage(ctx, in, out)
+}
+
+// Run feeds in to the pipeline and passes every result to sink, both may be
+// nil. It returns after all stages have exited, with the first error
+// returned by a stage or by sink.
+func (p *Pipeline[In, Out]) Run(ctx context.Context, in chan In, sink func(Out) error) error {
+	g := &stageGroup{}
+	g.ctx, g.cancel = context.WithCancel(ctx)
+	defer g.cancel()
+
+	// the caller owns in, so it is forwarded rather than handed to the
+	// first stage, which would drain it until the caller closes it;
+	// src keeps the capacity the caller gave in
+	src := make(chan In, cap(in))
+	if in == nil {
+		close(src)
+	} else {
+		g.wg.Add(1)
+		go func() {
+			defer g.wg.Done()
+			defer close(src)
+			for {
+				value, ok, err := recv(g.ctx, in)
+				if err != nil || !ok {
+					return
+				}
+				if send(g.ctx, src, value) != nil {
+					return
+				}
+			}
+		}()
+	}
+	for value := range p.start(g, src) {
+		if sink == nil || g.ctx.Err() != nil {
+			continue
+		}
+		if err := sink(value); err != nil {
+			g.fail(err)
+		}
+	}
+	// the last stage has returned, stages before it that still run
+	// have nobody left to send to and only wait for the cancellation
+	g.stop()
+	g.wg.Wait()
+	return g.firstErr
+}
+
+// ExecutePipelineContext runs jobs like ExecutePipeline, but the first error
+// or the cancellation of ctx stops every stage. It returns after all stages
+// have exited, with the first error that happened.
+func ExecutePipelineContext(ctx context.Context, jobs ...ctxJob) error {
+	if len(jobs) == 0 {
+		return nil
+	}
+	p := NewPipeline(jobs[0])
+	for _, job := range jobs[1:] {
+		p = Then(p, job)
+	}
+	return p.Run(ctx, nil, nil)
+}
+
+// adapt turns a typed stage into an untyped job, values of the wrong
+// type fail the pipeline with ErrUnexpectedType
+func adapt[In, Out any](name string, stage Stage[In, Out]) ctxJob {
+	return func(ctx context.Context, in, out chan interface{}) error {
+		typed := NewPipeline(func(ctx context.Context, _ chan interface{}, typedIn chan In) error {
+			for {
+				input, ok, err := recv(ctx, in)
+				if err != nil || !ok {
+					return err
+				}
+				value, ok := input.(In)
+				if !ok {
+					return fmt.Errorf("%s: %w %T", name, ErrUnexpectedType, input)
+				}
+				if err := send(ctx, typedIn, value); err != nil {
+					return err
+				}
+			}
+		})
+		return Then(typed, stage).Run(ctx, nil, func(value Out) error {
+			return send(ctx, out, interface{}(value))
+		})
+	}
+}
+
+// recv reads the next value from in, ok is false once in is closed
+func recv[T any](ctx context.Context, in chan T) (value T, ok bool, err error) {
+	select {
+	case <-ctx.Done():
+		return value, false, ctx.Err()
+	case value, ok = <-in:
+		return value, ok, nil
+	}
+}
+
+// send writes value to out unless ctx is done first
+func send[T any](ctx context.Context, out chan T, value T) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case out <- value:
+		return nil
+	}
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPipelineContextSigner(t *testing.T) {
+	expected := "29568666068035183841425683795340791879727309630931025356555_4958044192186797981418233587017209679042592862002427381542"
+	var result string
+	jobs := []ctxJob{
+		func(ctx context.Context, in, out chan interface{}) error {
+			for _, num := range []int{0, 1} {
+				if err := send(ctx, out, interface{}(num)); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		SingleHashContext,
+		MultiHashContext,
+		CombineResultsContext,
+		func(ctx context.Context, in, out chan interface{}) error {
+			value, _, err := recv(ctx, in)
+			result, _ = value.(string)
+			return err
+		},
+	}
+	if err := ExecutePipelineContext(context.Background(), jobs...); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result != expected {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", result, expected)
+	}
+}
+
+func TestPipelineContextError(t *testing.T) {
+	before := runtime.NumGoroutine()
+	jobs := []ctxJob{
+		// endless producer, it only stops because of the cancellation
+		func(ctx context.Context, in, out chan interface{}) error {
+			for i := 0; ; i++ {
+				if err := send(ctx, out, interface{}(i)); err != nil {
+					return err
+				}
+			}
+		},
+		// ignores ctx on purpose, the pipeline has to drain it
+		func(ctx context.Context, in, out chan interface{}) error {
+			for value := range in {
+				out <- value
+			}
+			return nil
+		},
+		SingleHashContext,
+		// wrong type for MultiHash
+		func(ctx context.Context, in, out chan interface{}) error {
+			return send(ctx, out, interface{}(42))
+		},
+		MultiHashContext,
+	}
+
+	start := time.Now()
+	err := ExecutePipelineContext(context.Background(), jobs...)
+	if !errors.Is(err, ErrUnexpectedType) {
+		t.Errorf("expected ErrUnexpectedType, got %v", err)
+	}
+	if time.Since(start) > 3*time.Second {
+		t.Errorf("pipeline did not stop in time: %s", time.Since(start))
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("goroutines leaked: %d before, %d after", before, after)
+	}
+}
+
+func TestPipelineContextCancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	jobs := []ctxJob{
+		func(ctx context.Context, in, out chan interface{}) error {
+			for i := 0; ; i++ {
+				if err := send(ctx, out, interface{}(i)); err != nil {
+					return err
+				}
+			}
+		},
+		func(ctx context.Context, in, out chan interface{}) error {
+			for {
+				_, ok, err := recv(ctx, in)
+				if err != nil || !ok {
+					return err
+				}
+			}
+		},
+	}
+	err := ExecutePipelineContext(ctx, jobs...)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestPipelineEarlyReturn(t *testing.T) {
+	// the middle job stops after one item, the last one has to see
+	// the end of its input without waiting for the slow source
+	closed := make(chan time.Duration, 1)
+	start := time.Now()
+	ExecutePipeline(
+		job(func(in, out chan interface{}) {
+			out <- 1
+			time.Sleep(time.Second)
+			out <- 2
+		}),
+		job(func(in, out chan interface{}) {
+			out <- <-in
+		}),
+		job(func(in, out chan interface{}) {
+			for range in {
+			}
+			closed <- time.Since(start)
+		}),
+	)
+	if elapsed := <-closed; elapsed > 500*time.Millisecond {
+		t.Errorf("last job saw its input close after %v", elapsed)
+	}
+
+	// behind an endless source an early nil return still ends the pipeline
+	done := make(chan error, 1)
+	go func() {
+		done <- ExecutePipelineContext(context.Background(),
+			func(ctx context.Context, in, out chan interface{}) error {
+				for i := 0; ; i++ {
+					if err := send(ctx, out, interface{}(i)); err != nil {
+						return err
+					}
+				}
+			},
+			func(ctx context.Context, in, out chan interface{}) error {
+				<-in
+				return nil
+			},
+		)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("pipeline did not end after the last stage returned")
+	}
+}
+
+func TestPipelineEndlessInput(t *testing.T) {
+	in := make(chan int)
+	stopProducer := make(chan struct{})
+	defer close(stopProducer)
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case in <- i:
+			case <-stopProducer:
+				return
+			}
+		}
+	}()
+
+	errBad := errors.New("bad")
+	failing := Stage[int, int](func(ctx context.Context, in chan int, out chan int) error {
+		<-in
+		return errBad
+	})
+	done := make(chan error, 1)
+	go func() {
+		done <- NewPipeline(failing).Run(context.Background(), in, nil)
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, errBad) {
+			t.Errorf("expected %v, got %v", errBad, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Run kept reading the endless input after the stage failed")
+	}
+}
+
+func TestTypedPipeline(t *testing.T) {
+	double := Stage[int, int](func(ctx context.Context, in, out chan int) error {
+		for value := range in {
+			if err := send(ctx, out, value*2); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	format := Stage[int, string](func(ctx context.Context, in chan int, out chan string) error {
+		for value := range in {
+			if err := send(ctx, out, strconv.Itoa(value)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	in := make(chan int, 3)
+	in <- 1
+	in <- 2
+	in <- 3
+	close(in)
+
+	var result []string
+	err := Then(NewPipeline(double), format).Run(context.Background(), in, func(value string) error {
+		result = append(result, value)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(result, ",") != "2,4,6" {
+		t.Errorf("results not match\nGot: %v\nExpected: 2,4,6", result)
+	}
+
+	errStop := errors.New("stop")
+	err = NewPipeline(format).Run(context.Background(), nil, func(string) error { return errStop })
+	if err != nil {
+		t.Errorf("empty input should not reach the sink, got %v", err)
+	}
+}
+
+func TestParallelStage(t *testing.T) {
+	var (
+		running int32
+		peak    int32
+	)
+	slowSquare := func(ctx context.Context, value int) (int, error) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			max := atomic.LoadInt32(&peak)
+			if n <= max || atomic.CompareAndSwapInt32(&peak, max, n) {
+				break
+			}
+		}
+		// later items finish first so that only ordering restores the input order
+		time.Sleep(time.Duration(10-value) * 5 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return value * value, nil
+	}
+
+	in := make(chan int, 10)
+	for i := 0; i < 10; i++ {
+		in <- i
+	}
+	close(in)
+
+	var result []string
+	stage := Parallel(slowSquare, StageOptions{Workers: 3, Ordered: true})
+	err := NewPipeline(stage, StageOptions{Buffer: -1}).Run(context.Background(), in, func(value int) error {
+		result = append(result, strconv.Itoa(value))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "0,1,4,9,16,25,36,49,64,81"
+	if strings.Join(result, ",") != expected {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", strings.Join(result, ","), expected)
+	}
+	if peak > 3 {
+		t.Errorf("too many workers: %d, expected at most 3", peak)
+	}
+
+	errBad := errors.New("bad item")
+	failing := Parallel(func(ctx context.Context, value int) (int, error) {
+		if value == 5 {
+			return 0, errBad
+		}
+		return value, nil
+	}, StageOptions{Workers: 2})
+	in = make(chan int)
+	go func() {
+		defer close(in)
+		for i := 0; i < 100; i++ {
+			in <- i
+		}
+	}()
+	err = NewPipeline(failing).Run(context.Background(), in, func(int) error { return nil })
+	if !errors.Is(err, errBad) {
+		t.Errorf("expected %v, got %v", errBad, err)
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Recipe describes a signature scheme in terms of registered signers.
+// A chain like "crc32(md5)" applies the innermost signer first.
+type Recipe struct {
+	// Single are the chains applied to the input of SingleHash, joined by Separator
+	Single    []string `json:"single"`
+	Separator string   `json:"separator"`
+	// Multi is applied to th+data for th = 0..Rounds-1, the results are concatenated
+	Multi  string `json:"multi"`
+	Rounds int    `json:"rounds"`
+	// Join glues the sorted MultiHash results in CombineResults
+	Join string `json:"join"`
+	// Cache memoizes up to Cache results of every signer, 0 turns it off
+	Cache int `json:"cache"`
+}
+
+// DefaultRecipe is the scheme of SingleHash, MultiHash and CombineResults
+func DefaultRecipe() Recipe {
+	return Recipe{
+		Single:    []string{"crc32", "crc32(md5)"},
+		Separator: "~",
+		Multi:     "crc32",
+		Rounds:    6,
+		Join:      "_",
+	}
+}
+
+// LoadRecipe reads a JSON recipe, missing fields keep their DefaultRecipe values
+func LoadRecipe(r io.Reader) (Recipe, error) {
+	recipe := DefaultRecipe()
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&recipe); err != nil {
+		return Recipe{}, fmt.Errorf("recipe: %w", err)
+	}
+	return recipe, nil
+}
+
+// Scheme is a compiled Recipe with the signers resolved
+type Scheme struct {
+	single    []func(data string) string
+	separator string
+	multi     func(data string) string
+	rounds    int
+	join      string
+}
+
+// Compile checks the recipe against the signer registry
+func (r Recipe) Compile() (*Scheme, error) {
+	if len(r.Single) == 0 {
+		return nil, fmt.Errorf("recipe: no single hash chains")
+	}
+	if r.Rounds < 1 {
+		return nil, fmt.Errorf("recipe: rounds must be positive, got %d", r.Rounds)
+	}
+	s := &Scheme{separator: r.Separator, rounds: r.Rounds, join: r.Join}
+	lookup := Signer
+	if r.Cache > 0 {
+		memoized := map[string]func(data string) string{}
+		lookup = func(name string) (func(data string) string, bool) {
+			if f, ok := memoized[name]; ok {
+				return f, true
+			}
+			f, ok := Signer(name)
+			if ok {
+				f = Memoized(NewCache(r.Cache), f)
+				memoized[name] = f
+			}
+			return f, ok
+		}
+	}
+	for _, expr := range r.Single {
+		f, err := parseChain(expr, lookup)
+		if err != nil {
+			return nil, err
+		}
+		s.single = append(s.single, f)
+	}
+	var err error
+	if s.multi, err = parseChain(r.Multi, lookup); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// parseChain turns "a(b(c))" into a(b(c(data))), lookup resolves the names
+func parseChain(expr string, lookup func(name string) (func(data string) string, bool)) (func(data string) string, error) {
+	trimmed := strings.TrimSpace(expr)
+	body := strings.TrimRight(trimmed, ")")
+	names := strings.Split(body, "(")
+	if len(trimmed)-len(body) != len(names)-1 {
+		return nil, fmt.Errorf("recipe: unbalanced chain %q", expr)
+	}
+	chain := make([]func(data string) string, len(names))
+	for i, name := range names {
+		name = strings.TrimSpace(name)
+		f, ok := lookup(name)
+		if !ok {
+			return nil, fmt.Errorf("recipe: unknown signer %q in %q, have %s",
+				name, expr, strings.Join(SignerNames(), ", "))
+		}
+		chain[i] = f
+	}
+	return func(data string) string {
+		for i := len(chain) - 1; i >= 0; i-- {
+			data = chain[i](data)
+		}
+		return data
+	}, nil
+}
+
+// SingleHash computes every chain of the recipe concurrently
+func (s *Scheme) SingleHash(data string) string {
+	parts := make([]chan string, len(s.single))
+	for i, f := range s.single {
+		parts[i] = asyncFunc(f, data)
+	}
+	result := make([]string, len(parts))
+	for i, part := range parts {
+		result[i] = <-part
+	}
+	return strings.Join(result, s.separator)
+}
+
+// MultiHash computes all rounds concurrently and concatenates them in order
+func (s *Scheme) MultiHash(data string) string {
+	hashes := make([]chan string, s.rounds)
+	for th := range hashes {
+		hashes[th] = asyncFunc(s.multi, strconv.Itoa(th)+data)
+	}
+	result := ""
+	for _, hash := range hashes {
+		result += <-hash
+	}
+	return result
+}
+
+// Combine sorts the results and glues them together
+func (s *Scheme) Combine(str []string) string {
+	sort.Strings(str)
+	return strings.Join(str, s.join)
+}
+
+// SingleHashStage runs SingleHash on every item, opts bound its workers
+func (s *Scheme) SingleHashStage(opts StageOptions) Stage[int, string] {
+	return Parallel(func(ctx context.Context, value int) (string, error) {
+		return s.SingleHash(strconv.Itoa(value)), nil
+	}, opts)
+}
+
+// MultiHashStage runs MultiHash on every item, opts bound its workers
+func (s *Scheme) MultiHashStage(opts StageOptions) Stage[string, string] {
+	return Parallel(func(ctx context.Context, value string) (string, error) {
+		return s.MultiHash(value), nil
+	}, opts)
+}
+
+// CombineStage collects the whole input and emits one combined result
+func (s *Scheme) CombineStage(ctx context.Context, in chan string, out chan string) error {
+	var str []string
+	for {
+		value, ok, err := recv(ctx, in)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return send(ctx, out, s.Combine(str))
+		}
+		str = append(str, value)
+	}
+}
+
+// Pipeline builds the whole signer for the scheme, opts apply to the hash stages
+func (s *Scheme) Pipeline(opts StageOptions) *Pipeline[int, string] {
+	return Then(Then(NewPipeline(s.SingleHashStage(opts), opts), s.MultiHashStage(opts), opts), s.CombineStage)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestXxhash(t *testing.T) {
+	cases := map[string]uint64{
+		"":    0xef46db3751d8e999,
+		"a":   0xd24ec4f1a98c6e5b,
+		"abc": 0x44bc2cf5ad770999,
+		"Nobody inspects the spammish repetition": 0xfbcea83c8a378bf1,
+	}
+	for input, expected := range cases {
+		if got := xxhash64([]byte(input)); got != expected {
+			t.Errorf("xxhash64(%q) = %x, expected %x", input, got, expected)
+		}
+	}
+}
+
+func TestRecipe(t *testing.T) {
+	scheme, err := DefaultRecipe().Compile()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	in := make(chan int, 2)
+	in <- 0
+	in <- 1
+	close(in)
+	var result string
+	err = scheme.Pipeline(StageOptions{}).Run(context.Background(), in, func(value string) error {
+		result = value
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "29568666068035183841425683795340791879727309630931025356555_4958044192186797981418233587017209679042592862002427381542"
+	if result != expected {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", result, expected)
+	}
+
+	recipe, err := LoadRecipe(strings.NewReader(`{"single": ["hmac(sha256) "], "multi": " xxhash", "rounds": 2}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if scheme, err = recipe.Compile(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sum := fmt.Sprintf("%x", sha256.Sum256([]byte("42")))
+	mac := hmac.New(sha256.New, nil)
+	mac.Write([]byte(sum))
+	expected = fmt.Sprintf("%x", mac.Sum(nil))
+	if got := scheme.SingleHash("42"); got != expected {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", got, expected)
+	}
+	expected = fmt.Sprintf("%016x%016x", xxhash64([]byte("0a")), xxhash64([]byte("1a")))
+	if got := scheme.MultiHash("a"); got != expected {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", got, expected)
+	}
+
+	for _, bad := range []string{
+		`{"single": ["crc32(nope)"]}`,
+		`{"single": ["crc32(md5"]}`,
+		`{"rounds": 0}`,
+	} {
+		recipe, err := LoadRecipe(strings.NewReader(bad))
+		if err == nil {
+			_, err = recipe.Compile()
+		}
+		if err == nil {
+			t.Errorf("expected an error for %s", bad)
+		}
+	}
+	if _, err := LoadRecipe(strings.NewReader(`{"singel": []}`)); err == nil {
+		t.Errorf("expected an error for an unknown field")
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+)
+
+// PanicError is a panic of a stage turned into an error
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("stage panicked: %v", e.Value)
+}
+
+// recovered converts the result of recover into an error, nil stays nil
+func recovered(r interface{}) error {
+	if r == nil {
+		return nil
+	}
+	return &PanicError{Value: r, Stack: debug.Stack()}
+}
+
+// RetryPolicy reruns a failing item of a Parallel stage
+type RetryPolicy struct {
+	// Attempts is the number of tries, 0 and 1 mean no retries
+	Attempts int
+	// Backoff is the wait before the second try, it doubles for every next one
+	Backoff time.Duration
+	// MaxBackoff caps the wait, 0 means no cap
+	MaxBackoff time.Duration
+}
+
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < attempt && (p.MaxBackoff == 0 || d < p.MaxBackoff); i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	return d
+}
+
+// DeadLetter is an item that failed every attempt of its stage
+type DeadLetter struct {
+	Value    interface{}
+	Err      error
+	Attempts int
+}
+
+// retry calls fn until it succeeds, the policy runs out of attempts or ctx is done.
+// Panics of fn count as failed attempts.
+func retry[In, Out any](ctx context.Context, p RetryPolicy, fn func(ctx context.Context, value In) (Out, error), value In) (result Out, attempts int, err error) {
+	for {
+		attempts++
+		result, err = protect(ctx, fn, value)
+		if err == nil || attempts >= p.Attempts || ctx.Err() != nil {
+			return result, attempts, err
+		}
+		timer := time.NewTimer(p.backoff(attempts))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return result, attempts, err
+		case <-timer.C:
+		}
+	}
+}
+
+func protect[In, Out any](ctx context.Context, fn func(ctx context.Context, value In) (Out, error), value In) (result Out, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = recovered(r)
+		}
+	}()
+	return fn(ctx, value)
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPanicIsolation(t *testing.T) {
+	err := ExecutePipelineContext(context.Background(),
+		func(ctx context.Context, in, out chan interface{}) error {
+			out <- "not an int"
+			return nil
+		},
+		func(ctx context.Context, in, out chan interface{}) error {
+			for input := range in {
+				_ = input.(int)
+			}
+			return nil
+		},
+	)
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) {
+		t.Fatalf("expected a PanicError, got %v", err)
+	}
+	if !strings.Contains(string(panicErr.Stack), "retry_test.go") {
+		t.Errorf("expected the stack of the panic, got:\n%s", panicErr.Stack)
+	}
+
+	// the plain pipeline raises the panic again in the calling goroutine
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("expected a panic from ExecutePipeline")
+		} else if err, ok := r.(error); !ok || !errors.As(err, &panicErr) || panicErr.Value != "type accession error" {
+			t.Errorf("unexpected panic: %v", r)
+		}
+	}()
+	ExecutePipeline(
+		job(func(in, out chan interface{}) {
+			out <- "not an int"
+		}),
+		job(SingleHash),
+	)
+}
+
+func TestPanicWaitsInFlight(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		result []interface{}
+	)
+	start := time.Now()
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Errorf("expected a panic from ExecutePipeline")
+			}
+		}()
+		ExecutePipeline(
+			job(func(in, out chan interface{}) {
+				out <- 1
+				out <- "not an int"
+			}),
+			job(SingleHash),
+			job(func(in, out chan interface{}) {
+				for value := range in {
+					mu.Lock()
+					result = append(result, value)
+					mu.Unlock()
+				}
+			}),
+		)
+	}()
+	// the hash of 1 was in flight when the bad item arrived, it has to be
+	// delivered before SingleHash returns instead of hitting a closed channel
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("ExecutePipeline returned before the hashes in flight: %v", elapsed)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(result) != 1 || result[0] != "2212294583~709660146" {
+		t.Errorf("results not match\nGot: %v\nExpected: [2212294583~709660146]", result)
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		attempts = map[int]int{}
+	)
+	dead := make(chan DeadLetter, 10)
+	flaky := Parallel(func(ctx context.Context, value int) (int, error) {
+		mu.Lock()
+		attempts[value]++
+		n := attempts[value]
+		mu.Unlock()
+		switch {
+		case value == 3:
+			panic("bad record")
+		case value == 5 && n < 3:
+			return 0, errors.New("temporary")
+		}
+		return value, nil
+	}, StageOptions{
+		Ordered:    true,
+		Retry:      RetryPolicy{Attempts: 3, Backoff: 5 * time.Millisecond},
+		DeadLetter: dead,
+	})
+
+	in := make(chan int, 6)
+	for i := 1; i <= 6; i++ {
+		in <- i
+	}
+	close(in)
+	var result []int
+	err := NewPipeline(flaky).Run(context.Background(), in, func(value int) error {
+		result = append(result, value)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	close(dead)
+	if len(result) != 5 || result[2] != 4 || result[3] != 5 {
+		t.Errorf("results not match\nGot: %v\nExpected: [1 2 4 5 6]", result)
+	}
+	if attempts[5] != 3 {
+		t.Errorf("expected 3 attempts for 5, got %d", attempts[5])
+	}
+	letter := <-dead
+	var panicErr *PanicError
+	if letter.Value != 3 || letter.Attempts != 3 || !errors.As(letter.Err, &panicErr) {
+		t.Errorf("unexpected dead letter: %+v", letter)
+	}
+
+	policy := RetryPolicy{Backoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}
+	for attempt, expected := range map[int]time.Duration{1: 10 * time.Millisecond, 3: 40 * time.Millisecond, 5: 50 * time.Millisecond} {
+		if got := policy.backoff(attempt); got != expected {
+			t.Errorf("backoff(%d) = %v, expected %v", attempt, got, expected)
+		}
+	}
+}
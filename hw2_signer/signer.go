@@ -1,33 +1,44 @@
 package main
 
 import (
-	"sort"
+	"context"
 	"strconv"
-	"strings"
 	"sync"
 )
 
-func worker(in, out chan interface{}, job job, wg *sync.WaitGroup) {
-	defer wg.Done()
-	defer close(out)
-	job(in, out)
-}
-
+// ExecutePipeline runs plain jobs on top of the typed pipeline, they do not
+// know about the context. A panic of a job stops the pipeline and is raised
+// again as a *PanicError in the calling goroutine, where it can be recovered.
 func ExecutePipeline(jobs ...job) {
-	in := make(chan interface{}, 10)
-	wg := &sync.WaitGroup{}
-	for _, job := range jobs {
-		out := make(chan interface{}, 10)
-		wg.Add(1)
-		go worker(in, out, job, wg)
-		in = out
+	ctxJobs := make([]ctxJob, 0, len(jobs))
+	for _, j := range jobs {
+		ctxJobs = append(ctxJobs, func(ctx context.Context, in, out chan interface{}) error {
+			j(in, out)
+			return nil
+		})
 	}
-	wg.Wait()
+	if err := ExecutePipelineContext(context.Background(), ctxJobs...); err != nil {
+		panic(err)
+	}
+}
+
+// md5Limit is shared by every md5 call of the package, DataSignerMd5
+// overheats globally and not per pipeline
+var md5Limit = NewSemaphore(1)
+
+// signMd5 is DataSignerMd5 behind md5Limit, the variable is looked up
+// at call time so that overriding it keeps working
+var signMd5 = Limited(md5Limit, func(data string) string { return DataSignerMd5(data) })
+
+// Md5LimiterStats shows how long md5 calls waited for each other
+func Md5LimiterStats() LimiterStats {
+	return md5Limit.Stats()
 }
 
 func SingleHash(in, out chan interface{}) {
-	maxMd5Func := make(chan struct{}, 1)
 	wg := &sync.WaitGroup{}
+	// on a bad item the hashes in flight still have to reach out before it is closed
+	defer wg.Wait()
 	for input := range in {
 		value, ok := input.(int)
 		if !ok {
@@ -36,19 +47,15 @@ func SingleHash(in, out chan interface{}) {
 		wg.Add(1)
 		go func(input string) {
 			defer wg.Done()
-			a := asyncFunc(DataSignerCrc32, input)
-			md5 := asyncFuncWithLimit(DataSignerMd5, input, maxMd5Func)
-			b := asyncFunc(DataSignerCrc32, <- md5)
-			out <- <-a + "~" + <-b
+			out <- defaultScheme.SingleHash(input)
 		}(strconv.Itoa(value))
 	}
-	wg.Wait()
 }
 
 func MultiHash(in, out chan interface{}) {
 	wg := &sync.WaitGroup{}
-	mu := &sync.Mutex{}
-	ths := []int{0,1,2,3,4,5}
+	// on a bad item the hashes in flight still have to reach out before it is closed
+	defer wg.Wait()
 	for input := range in {
 		value, ok := input.(string)
 		if !ok {
@@ -57,29 +64,9 @@ func MultiHash(in, out chan interface{}) {
 		wg.Add(1)
 		go func(input string) {
 			defer wg.Done()
-			waitStr := &sync.WaitGroup{}
-			hashes := map[int]string{}
-			for _, th := range ths {
-				waitStr.Add(1)
-				go func(n int) {
-					defer waitStr.Done()
-					res := <-asyncFunc(DataSignerCrc32, strconv.Itoa(n) + input)
-					mu.Lock()
-					hashes[n] = res
-					mu.Unlock()
-				}(th)
-			}
-			waitStr.Wait()
-			result := ""
-			for _, th := range ths {
-				mu.Lock()
-				result += hashes[th]
-				mu.Unlock()
-			}
-			out <- result
+			out <- defaultScheme.MultiHash(input)
 		}(value)
 	}
-	wg.Wait()
 }
 
 func CombineResults(in, out chan interface{}) {
@@ -87,19 +74,61 @@ func CombineResults(in, out chan interface{}) {
 	for input := range in {
 		str = append(str, input.(string))
 	}
-	sort.Strings(str)
-	result := strings.Join(str, "_")
-	out <- result
+	out <- defaultScheme.Combine(str)
 }
 
-func asyncFuncWithLimit(f func(data string) string, data string, limitCh chan struct{}) chan string {
-	result := make(chan string, 1)
-	go func(out chan<- string) {
-		limitCh <- struct{}{}
-		out <- f(data)
-		<- limitCh
-	}(result)
-	return result
+// defaultScheme is the only implementation of the signer algorithm,
+// the plain, context and typed variants all go through it
+var defaultScheme = mustCompile(DefaultRecipe())
+
+func mustCompile(recipe Recipe) *Scheme {
+	scheme, err := recipe.Compile()
+	if err != nil {
+		panic(err)
+	}
+	return scheme
+}
+
+// NewSingleHashStage builds the typed SingleHash, opts bound its workers
+// and may keep results in input order
+func NewSingleHashStage(opts StageOptions) Stage[int, string] {
+	return defaultScheme.SingleHashStage(opts)
+}
+
+// NewMultiHashStage builds the typed MultiHash, opts bound its workers
+// and may keep results in input order
+func NewMultiHashStage(opts StageOptions) Stage[string, string] {
+	return defaultScheme.MultiHashStage(opts)
+}
+
+// SingleHashStage is the typed SingleHash with a goroutine per item
+func SingleHashStage(ctx context.Context, in chan int, out chan string) error {
+	return NewSingleHashStage(StageOptions{})(ctx, in, out)
+}
+
+// MultiHashStage is the typed MultiHash with a goroutine per item
+func MultiHashStage(ctx context.Context, in chan string, out chan string) error {
+	return NewMultiHashStage(StageOptions{})(ctx, in, out)
+}
+
+// CombineResultsStage is the typed CombineResults
+func CombineResultsStage(ctx context.Context, in chan string, out chan string) error {
+	return defaultScheme.CombineStage(ctx, in, out)
+}
+
+// SingleHashContext is SingleHash for ExecutePipelineContext
+func SingleHashContext(ctx context.Context, in, out chan interface{}) error {
+	return adapt("SingleHash", SingleHashStage)(ctx, in, out)
+}
+
+// MultiHashContext is MultiHash for ExecutePipelineContext
+func MultiHashContext(ctx context.Context, in, out chan interface{}) error {
+	return adapt("MultiHash", MultiHashStage)(ctx, in, out)
+}
+
+// CombineResultsContext is CombineResults for ExecutePipelineContext
+func CombineResultsContext(ctx context.Context, in, out chan interface{}) error {
+	return adapt("CombineResults", CombineResultsStage)(ctx, in, out)
 }
 
 func asyncFunc(f func(data string) string, data string) chan string {
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// Window tells a windowed CombineResults when to emit. Whichever limit is
+// reached first closes the window, the zero Window waits for the end of input.
+type Window struct {
+	Count int           // emit after Count items
+	Every time.Duration // emit every period, empty windows are skipped
+	Gap   time.Duration // emit once no item arrived for Gap
+}
+
+// CountWindow emits every n items
+func CountWindow(n int) Window {
+	return Window{Count: n}
+}
+
+// TimeWindow emits whatever arrived in each period
+func TimeWindow(period time.Duration) Window {
+	return Window{Every: period}
+}
+
+// SessionWindow emits once the stream was quiet for gap
+func SessionWindow(gap time.Duration) Window {
+	return Window{Gap: gap}
+}
+
+// WindowedCombineStage is CombineResults that emits a sorted joined
+// result per window instead of once at the end of input
+func WindowedCombineStage(w Window) Stage[string, string] {
+	return func(ctx context.Context, in chan string, out chan string) error {
+		var str []string
+		flush := func() error {
+			if len(str) == 0 {
+				return nil
+			}
+			result := defaultScheme.Combine(str)
+			str = nil
+			return send(ctx, out, result)
+		}
+
+		var tick <-chan time.Time
+		if w.Every > 0 {
+			ticker := time.NewTicker(w.Every)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+		var (
+			gap   *time.Timer
+			quiet <-chan time.Time
+		)
+		if w.Gap > 0 {
+			gap = time.NewTimer(w.Gap)
+			gap.Stop()
+			defer gap.Stop()
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case value, ok := <-in:
+				if !ok {
+					return flush()
+				}
+				str = append(str, value)
+				if gap != nil {
+					gap.Reset(w.Gap)
+					quiet = gap.C
+				}
+				if w.Count > 0 && len(str) >= w.Count {
+					if err := flush(); err != nil {
+						return err
+					}
+				}
+			case <-tick:
+				if err := flush(); err != nil {
+					return err
+				}
+			case <-quiet:
+				quiet = nil
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// WindowedCombineResults is WindowedCombineStage for ExecutePipelineContext
+func WindowedCombineResults(w Window) ctxJob {
+	return adapt("CombineResults", WindowedCombineStage(w))
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWindowedCombine(t *testing.T) {
+	run := func(w Window, feed func(in chan string)) []string {
+		in := make(chan string)
+		go func() {
+			defer close(in)
+			feed(in)
+		}()
+		var result []string
+		err := NewPipeline(WindowedCombineStage(w)).Run(context.Background(), in, func(value string) error {
+			result = append(result, value)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return result
+	}
+
+	// a count window emits while the input is still open
+	in := make(chan string)
+	out := make(chan string)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go WindowedCombineStage(CountWindow(2))(ctx, in, out)
+	in <- "b"
+	in <- "a"
+	select {
+	case got := <-out:
+		if got != "a_b" {
+			t.Errorf("results not match\nGot: %v\nExpected: a_b", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("count window did not emit before the end of input")
+	}
+
+	result := run(CountWindow(2), func(in chan string) {
+		for _, v := range []string{"c", "a", "d", "b", "e"} {
+			in <- v
+		}
+	})
+	if strings.Join(result, " ") != "a_c b_d e" {
+		t.Errorf("results not match\nGot: %v\nExpected: a_c b_d e", strings.Join(result, " "))
+	}
+
+	result = run(SessionWindow(30*time.Millisecond), func(in chan string) {
+		in <- "y"
+		in <- "x"
+		time.Sleep(100 * time.Millisecond)
+		in <- "z"
+	})
+	if strings.Join(result, " ") != "x_y z" {
+		t.Errorf("results not match\nGot: %v\nExpected: x_y z", strings.Join(result, " "))
+	}
+
+	result = run(TimeWindow(50*time.Millisecond), func(in chan string) {
+		in <- "2"
+		in <- "1"
+		time.Sleep(120 * time.Millisecond)
+		in <- "3"
+	})
+	if strings.Join(result, " ") != "1_2 3" {
+		t.Errorf("results not match\nGot: %v\nExpected: 1_2 3", strings.Join(result, " "))
+	}
+}